@@ -0,0 +1,100 @@
+// Package admin implements the admin port of the book search and compute
+// servers: AdminAPI, the operational service, and DiagnosticsAPI next to the
+// channelz service, and optionally pprof.
+package admin
+
+import (
+	"context"
+
+	"github.com/vpulimamidi/grpc-go-course/admin/adminpb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/chaos"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/ratelimit"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements adminpb.AdminAPIServer. Components left nil report
+// codes.FailedPrecondition.
+type Server struct {
+	adminpb.UnimplementedAdminAPIServer
+
+	// Limiter provides the quota counters
+	Limiter *ratelimit.Limiter
+	// Chaos injects the faults configured through GetChaos and SetChaos
+	Chaos *chaos.Injector
+}
+
+// GetQuotaUsage returns the daily quota counters of the rate limiter
+func (s *Server) GetQuotaUsage(ctx context.Context, req *adminpb.GetQuotaUsageRequest) (*adminpb.GetQuotaUsageResponse, error) {
+	if s.Limiter == nil {
+		return nil, status.Error(codes.FailedPrecondition, "rate limiting is not enabled on this server")
+	}
+	res := &adminpb.GetQuotaUsageResponse{}
+	for _, u := range s.Limiter.Usage(req.GetClient(), req.GetMethod()) {
+		res.Usage = append(res.Usage, &adminpb.QuotaUsage{
+			Client:   u.Client,
+			Method:   u.Method,
+			Used:     u.Used,
+			Limit:    u.Limit,
+			ResetsAt: timestamppb.New(u.ResetsAt),
+		})
+	}
+	return res, nil
+}
+
+// GetChaos returns the faults injected by the server
+func (s *Server) GetChaos(ctx context.Context, req *adminpb.GetChaosRequest) (*adminpb.ChaosConfig, error) {
+	if s.Chaos == nil {
+		return nil, status.Error(codes.FailedPrecondition, "fault injection is not available on this server")
+	}
+	return chaosToProto(s.Chaos.Config()), nil
+}
+
+// SetChaos replaces the faults injected by the server, and returns them
+func (s *Server) SetChaos(ctx context.Context, req *adminpb.SetChaosRequest) (*adminpb.ChaosConfig, error) {
+	if s.Chaos == nil {
+		return nil, status.Error(codes.FailedPrecondition, "fault injection is not available on this server")
+	}
+	if err := s.Chaos.SetConfig(chaosFromProto(req.GetConfig())); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid chaos config: %v", err)
+	}
+	return chaosToProto(s.Chaos.Config()), nil
+}
+
+func chaosToProto(cfg chaos.Config) *adminpb.ChaosConfig {
+	res := &adminpb.ChaosConfig{Enabled: cfg.Enabled}
+	for _, r := range cfg.Rules {
+		res.Rules = append(res.Rules, &adminpb.ChaosRule{
+			Methods:            r.Methods,
+			Percentage:         r.Percentage,
+			Latency:            durationpb.New(r.Latency),
+			Jitter:             durationpb.New(r.Jitter),
+			Code:               r.Code,
+			Message:            r.Message,
+			AbortAfterMessages: uint32(r.AbortAfterMessages),
+			AbortCode:          r.AbortCode,
+			DropPercentage:     r.DropPercentage,
+		})
+	}
+	return res
+}
+
+func chaosFromProto(cfg *adminpb.ChaosConfig) chaos.Config {
+	res := chaos.Config{Enabled: cfg.GetEnabled()}
+	for _, r := range cfg.GetRules() {
+		res.Rules = append(res.Rules, chaos.Rule{
+			Methods:            r.GetMethods(),
+			Percentage:         r.GetPercentage(),
+			Latency:            r.GetLatency().AsDuration(),
+			Jitter:             r.GetJitter().AsDuration(),
+			Code:               r.GetCode(),
+			Message:            r.GetMessage(),
+			AbortAfterMessages: int(r.GetAbortAfterMessages()),
+			AbortCode:          r.GetAbortCode(),
+			DropPercentage:     r.GetDropPercentage(),
+		})
+	}
+	return res
+}
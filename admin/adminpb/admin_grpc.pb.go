@@ -0,0 +1,273 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.15.3
+// source: adminpb/admin.proto
+
+package adminpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AdminAPIClient is the client API for AdminAPI service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminAPIClient interface {
+	// Daily quota counters kept by the rate limiter
+	GetQuotaUsage(ctx context.Context, in *GetQuotaUsageRequest, opts ...grpc.CallOption) (*GetQuotaUsageResponse, error)
+	// Faults injected by the chaos interceptor
+	GetChaos(ctx context.Context, in *GetChaosRequest, opts ...grpc.CallOption) (*ChaosConfig, error)
+	// Replaces the faults injected by the chaos interceptor
+	SetChaos(ctx context.Context, in *SetChaosRequest, opts ...grpc.CallOption) (*ChaosConfig, error)
+}
+
+type adminAPIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminAPIClient(cc grpc.ClientConnInterface) AdminAPIClient {
+	return &adminAPIClient{cc}
+}
+
+func (c *adminAPIClient) GetQuotaUsage(ctx context.Context, in *GetQuotaUsageRequest, opts ...grpc.CallOption) (*GetQuotaUsageResponse, error) {
+	out := new(GetQuotaUsageResponse)
+	err := c.cc.Invoke(ctx, "/admin.AdminAPI/GetQuotaUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminAPIClient) GetChaos(ctx context.Context, in *GetChaosRequest, opts ...grpc.CallOption) (*ChaosConfig, error) {
+	out := new(ChaosConfig)
+	err := c.cc.Invoke(ctx, "/admin.AdminAPI/GetChaos", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminAPIClient) SetChaos(ctx context.Context, in *SetChaosRequest, opts ...grpc.CallOption) (*ChaosConfig, error) {
+	out := new(ChaosConfig)
+	err := c.cc.Invoke(ctx, "/admin.AdminAPI/SetChaos", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminAPIServer is the server API for AdminAPI service.
+// All implementations must embed UnimplementedAdminAPIServer
+// for forward compatibility
+type AdminAPIServer interface {
+	// Daily quota counters kept by the rate limiter
+	GetQuotaUsage(context.Context, *GetQuotaUsageRequest) (*GetQuotaUsageResponse, error)
+	// Faults injected by the chaos interceptor
+	GetChaos(context.Context, *GetChaosRequest) (*ChaosConfig, error)
+	// Replaces the faults injected by the chaos interceptor
+	SetChaos(context.Context, *SetChaosRequest) (*ChaosConfig, error)
+	mustEmbedUnimplementedAdminAPIServer()
+}
+
+// UnimplementedAdminAPIServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminAPIServer struct {
+}
+
+func (UnimplementedAdminAPIServer) GetQuotaUsage(context.Context, *GetQuotaUsageRequest) (*GetQuotaUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuotaUsage not implemented")
+}
+func (UnimplementedAdminAPIServer) GetChaos(context.Context, *GetChaosRequest) (*ChaosConfig, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChaos not implemented")
+}
+func (UnimplementedAdminAPIServer) SetChaos(context.Context, *SetChaosRequest) (*ChaosConfig, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetChaos not implemented")
+}
+func (UnimplementedAdminAPIServer) mustEmbedUnimplementedAdminAPIServer() {}
+
+// UnsafeAdminAPIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminAPIServer will
+// result in compilation errors.
+type UnsafeAdminAPIServer interface {
+	mustEmbedUnimplementedAdminAPIServer()
+}
+
+func RegisterAdminAPIServer(s grpc.ServiceRegistrar, srv AdminAPIServer) {
+	s.RegisterService(&AdminAPI_ServiceDesc, srv)
+}
+
+func _AdminAPI_GetQuotaUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuotaUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminAPIServer).GetQuotaUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/admin.AdminAPI/GetQuotaUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminAPIServer).GetQuotaUsage(ctx, req.(*GetQuotaUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminAPI_GetChaos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChaosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminAPIServer).GetChaos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/admin.AdminAPI/GetChaos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminAPIServer).GetChaos(ctx, req.(*GetChaosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminAPI_SetChaos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetChaosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminAPIServer).SetChaos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/admin.AdminAPI/SetChaos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminAPIServer).SetChaos(ctx, req.(*SetChaosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminAPI_ServiceDesc is the grpc.ServiceDesc for AdminAPI service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminAPI_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "admin.AdminAPI",
+	HandlerType: (*AdminAPIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetQuotaUsage",
+			Handler:    _AdminAPI_GetQuotaUsage_Handler,
+		},
+		{
+			MethodName: "GetChaos",
+			Handler:    _AdminAPI_GetChaos_Handler,
+		},
+		{
+			MethodName: "SetChaos",
+			Handler:    _AdminAPI_SetChaos_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "adminpb/admin.proto",
+}
+
+// DiagnosticsAPIClient is the client API for DiagnosticsAPI service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DiagnosticsAPIClient interface {
+	// Connections, streams, per-method counters, configuration, build and
+	// runtime of the server
+	GetDiagnostics(ctx context.Context, in *GetDiagnosticsRequest, opts ...grpc.CallOption) (*Diagnostics, error)
+}
+
+type diagnosticsAPIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDiagnosticsAPIClient(cc grpc.ClientConnInterface) DiagnosticsAPIClient {
+	return &diagnosticsAPIClient{cc}
+}
+
+func (c *diagnosticsAPIClient) GetDiagnostics(ctx context.Context, in *GetDiagnosticsRequest, opts ...grpc.CallOption) (*Diagnostics, error) {
+	out := new(Diagnostics)
+	err := c.cc.Invoke(ctx, "/admin.DiagnosticsAPI/GetDiagnostics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DiagnosticsAPIServer is the server API for DiagnosticsAPI service.
+// All implementations must embed UnimplementedDiagnosticsAPIServer
+// for forward compatibility
+type DiagnosticsAPIServer interface {
+	// Connections, streams, per-method counters, configuration, build and
+	// runtime of the server
+	GetDiagnostics(context.Context, *GetDiagnosticsRequest) (*Diagnostics, error)
+	mustEmbedUnimplementedDiagnosticsAPIServer()
+}
+
+// UnimplementedDiagnosticsAPIServer must be embedded to have forward compatible implementations.
+type UnimplementedDiagnosticsAPIServer struct {
+}
+
+func (UnimplementedDiagnosticsAPIServer) GetDiagnostics(context.Context, *GetDiagnosticsRequest) (*Diagnostics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDiagnostics not implemented")
+}
+func (UnimplementedDiagnosticsAPIServer) mustEmbedUnimplementedDiagnosticsAPIServer() {}
+
+// UnsafeDiagnosticsAPIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DiagnosticsAPIServer will
+// result in compilation errors.
+type UnsafeDiagnosticsAPIServer interface {
+	mustEmbedUnimplementedDiagnosticsAPIServer()
+}
+
+func RegisterDiagnosticsAPIServer(s grpc.ServiceRegistrar, srv DiagnosticsAPIServer) {
+	s.RegisterService(&DiagnosticsAPI_ServiceDesc, srv)
+}
+
+func _DiagnosticsAPI_GetDiagnostics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDiagnosticsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DiagnosticsAPIServer).GetDiagnostics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/admin.DiagnosticsAPI/GetDiagnostics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DiagnosticsAPIServer).GetDiagnostics(ctx, req.(*GetDiagnosticsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DiagnosticsAPI_ServiceDesc is the grpc.ServiceDesc for DiagnosticsAPI service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DiagnosticsAPI_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "admin.DiagnosticsAPI",
+	HandlerType: (*DiagnosticsAPIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetDiagnostics",
+			Handler:    _DiagnosticsAPI_GetDiagnostics_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "adminpb/admin.proto",
+}
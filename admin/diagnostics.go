@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"context"
+	"flag"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/admin/adminpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Diagnostics implements adminpb.DiagnosticsAPIServer
+type Diagnostics struct {
+	adminpb.UnimplementedDiagnosticsAPIServer
+
+	// Tracker provides the connections and the method counters, none are
+	// reported when nil
+	Tracker *Tracker
+	// Flags are the command line flags reported as the configuration,
+	// flag.CommandLine when nil
+	Flags *flag.FlagSet
+
+	started time.Time
+}
+
+// NewDiagnostics returns the diagnostics of a server started now, reporting
+// the connections and calls seen by tracker
+func NewDiagnostics(tracker *Tracker) *Diagnostics {
+	return &Diagnostics{Tracker: tracker, started: time.Now()}
+}
+
+// GetDiagnostics returns the state of the server
+func (d *Diagnostics) GetDiagnostics(ctx context.Context, req *adminpb.GetDiagnosticsRequest) (*adminpb.Diagnostics, error) {
+	res := &adminpb.Diagnostics{
+		Build:   buildInfo(),
+		Runtime: d.runtimeStats(),
+	}
+	fs := d.Flags
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	fs.VisitAll(func(f *flag.Flag) {
+		res.Flags = append(res.Flags, &adminpb.Flag{
+			Name:    f.Name,
+			Value:   f.Value.String(),
+			Default: f.DefValue,
+			Set:     set[f.Name],
+		})
+	})
+	if d.Tracker == nil {
+		return res, nil
+	}
+	for _, c := range d.Tracker.Connections() {
+		res.Connections = append(res.Connections, &adminpb.Connection{
+			RemoteAddr:     c.RemoteAddr,
+			LocalAddr:      c.LocalAddr,
+			EstablishedAt:  timestamppb.New(c.Established),
+			ActiveStreams:  c.ActiveStreams,
+			StreamsStarted: c.StreamsStarted,
+		})
+	}
+	for _, m := range d.Tracker.Methods() {
+		res.Methods = append(res.Methods, &adminpb.MethodStats{
+			Method:           m.Method,
+			Started:          m.Started,
+			InFlight:         m.InFlight,
+			Codes:            m.Codes,
+			MessagesReceived: m.MessagesReceived,
+			MessagesSent:     m.MessagesSent,
+		})
+	}
+	return res, nil
+}
+
+func buildInfo() *adminpb.BuildInfo {
+	res := &adminpb.BuildInfo{GoVersion: runtime.Version(), Os: runtime.GOOS, Arch: runtime.GOARCH}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return res
+	}
+	res.Path = info.Main.Path
+	res.Version = info.Main.Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			res.Revision = s.Value
+		case "vcs.time":
+			if t, err := time.Parse(time.RFC3339, s.Value); err == nil {
+				res.RevisionTime = timestamppb.New(t)
+			}
+		case "vcs.modified":
+			res.Modified = s.Value == "true"
+		}
+	}
+	return res
+}
+
+func (d *Diagnostics) runtimeStats() *adminpb.RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	res := &adminpb.RuntimeStats{
+		Goroutines:   int32(runtime.NumGoroutine()),
+		Gomaxprocs:   int32(runtime.GOMAXPROCS(0)),
+		NumCpu:       int32(runtime.NumCPU()),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapSys:      mem.HeapSys,
+		HeapObjects:  mem.HeapObjects,
+		TotalAlloc:   mem.TotalAlloc,
+		NumGc:        mem.NumGC,
+		GcPauseTotal: durationpb.New(time.Duration(mem.PauseTotalNs)),
+	}
+	if !d.started.IsZero() {
+		res.StartedAt = timestamppb.New(d.started)
+		res.Uptime = durationpb.New(time.Since(d.started))
+	}
+	if mem.LastGC > 0 {
+		res.LastGc = timestamppb.New(time.Unix(0, int64(mem.LastGC)))
+	}
+	return res
+}
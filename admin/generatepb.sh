@@ -0,0 +1,2 @@
+#!/bin/bash
+protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative adminpb/admin.proto
\ No newline at end of file
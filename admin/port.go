@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/admin/adminpb"
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/rbac"
+	"github.com/vpulimamidi/grpc-go-course/web"
+	"google.golang.org/grpc"
+	channelzservice "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Role is the role the callers of the admin port must hold when the port
+// checks credentials
+const Role = "admin"
+
+// PortOptions configure the admin port of a server
+type PortOptions struct {
+	// Admin serves AdminAPI, which reads the quota counters and controls the
+	// fault injection, so it is only registered on this port
+	Admin *Server
+	// Diagnostics serves DiagnosticsAPI
+	Diagnostics *Diagnostics
+	// Authenticator verifies the credentials of the callers, who must hold
+	// Role. When nil, the gRPC services are open to anyone reaching the port,
+	// which must then listen on a loopback address, and Pprof is refused.
+	Authenticator *auth.Authenticator
+	// Pprof serves the net/http/pprof handlers under /debug/pprof/ to
+	// HTTP/1.x clients
+	Pprof bool
+}
+
+// ServePort serves the admin port on lis from background goroutines: the
+// channelz service, AdminAPI, DiagnosticsAPI and reflection over gRPC, and pprof over
+// HTTP/1.x when enabled. The port is meant to stay off the load balancers,
+// for operators only; without an Authenticator it must listen on a loopback
+// address. The returned servers stop the port, the HTTP one is nil without
+// pprof.
+func ServePort(lis net.Listener, o PortOptions) (*grpc.Server, *http.Server, error) {
+	if o.Authenticator == nil {
+		if o.Pprof {
+			return nil, nil, errors.New("pprof needs credentials, the port would expose the memory of the server to anyone")
+		}
+		if addr, ok := lis.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+			return nil, nil, fmt.Errorf("the admin port listens on %v without credentials, listen on a loopback address or configure credentials", addr)
+		}
+	}
+	var opts []grpc.ServerOption
+	if o.Authenticator != nil {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(auth.UnaryServerInterceptor(o.Authenticator), unaryRequireRole),
+			grpc.ChainStreamInterceptor(auth.StreamServerInterceptor(o.Authenticator), streamRequireRole),
+		)
+	}
+	s := grpc.NewServer(opts...)
+	channelzservice.RegisterChannelzServiceToServer(s)
+	if o.Admin != nil {
+		adminpb.RegisterAdminAPIServer(s, o.Admin)
+	}
+	if o.Diagnostics != nil {
+		adminpb.RegisterDiagnosticsAPIServer(s, o.Diagnostics)
+	}
+	reflection.Register(s)
+	grpcLis := lis
+	var srv *http.Server
+	if o.Pprof {
+		var httpLis net.Listener
+		grpcLis, httpLis = web.Split(lis)
+		srv = &http.Server{Handler: pprofHandler(o.Authenticator), ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := srv.Serve(httpLis); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("pprof listener failed: %v", err)
+			}
+		}()
+	}
+	go func() {
+		if err := s.Serve(grpcLis); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("Admin listener failed: %v", err)
+		}
+	}()
+	return s, srv, nil
+}
+
+// authorize returns an error unless the caller of ctx holds Role
+func authorize(ctx context.Context) error {
+	id, ok := auth.FromContext(ctx)
+	if !ok || !slices.Contains(id.Roles, Role) {
+		return apierror.ServerDomain.Error(codes.PermissionDenied, rbac.ReasonPermissionDenied,
+			fmt.Sprintf("the admin port requires the %s role", Role), nil)
+	}
+	return nil
+}
+
+func unaryRequireRole(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func streamRequireRole(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// pprofHandler serves the pprof handlers to the callers authenticated by a
+// with Role, presenting the same headers as gRPC callers
+func pprofHandler(a *auth.Authenticator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md := metadata.MD{}
+		for _, key := range []string{auth.AuthorizationHeader, auth.APIKeyHeader} {
+			if values := r.Header.Values(key); len(values) > 0 {
+				md[key] = values
+			}
+		}
+		id, err := a.Authenticate(metadata.NewIncomingContext(r.Context(), md))
+		if err != nil {
+			http.Error(w, status.Convert(err).Message(), http.StatusUnauthorized)
+			return
+		}
+		ctx := auth.NewContext(r.Context(), id)
+		if err := authorize(ctx); err != nil {
+			http.Error(w, status.Convert(err).Message(), http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,160 @@
+package admin_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/admin"
+	"github.com/vpulimamidi/grpc-go-course/admin/adminpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// keys of config/api-keys.yaml
+const (
+	adminKey  = "dev-admin-key"
+	readerKey = "dev-reader-key"
+)
+
+func servePort(t *testing.T, o admin.PortOptions) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, srv, err := admin.ServePort(lis, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Stop)
+	if srv != nil {
+		t.Cleanup(func() { srv.Close() })
+	}
+	return lis.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestDiagnostics(t *testing.T) {
+	tracker := admin.NewTracker()
+	h := testharness.Start(t, testharness.Options{ServerOptions: []grpc.ServerOption{grpc.StatsHandler(tracker)}})
+	ctx := context.Background()
+	for _, title := range []string{"Java", "Java", "no such book"} {
+		h.Books.GetBook(ctx, &bookpb.GetBookRequest{Title: title})
+	}
+	addr := servePort(t, admin.PortOptions{Diagnostics: admin.NewDiagnostics(tracker)})
+	res, err := adminpb.NewDiagnosticsAPIClient(dial(t, addr)).GetDiagnostics(ctx, &adminpb.GetDiagnosticsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var getBook *adminpb.MethodStats
+	for _, m := range res.GetMethods() {
+		if m.GetMethod() == "/book.BookSearchAPI/GetBook" {
+			getBook = m
+		}
+	}
+	if getBook == nil {
+		t.Fatalf("no counters for GetBook in %v", res.GetMethods())
+	}
+	if getBook.GetStarted() != 3 || getBook.GetInFlight() != 0 || getBook.GetCodes()["OK"] != 2 || getBook.GetCodes()["NotFound"] != 1 {
+		t.Errorf("GetBook counters = %v, want 3 calls: 2 OK and 1 NotFound", getBook)
+	}
+	// the calls have returned, so the harness connections are idle
+	if len(res.GetConnections()) == 0 || res.GetConnections()[0].GetActiveStreams() != 0 {
+		t.Errorf("connections = %v, want the idle harness connections", res.GetConnections())
+	}
+	if res.GetBuild().GetGoVersion() == "" || res.GetRuntime().GetGoroutines() == 0 || res.GetRuntime().GetUptime() == nil {
+		t.Errorf("build = %v, runtime = %v, want them filled", res.GetBuild(), res.GetRuntime())
+	}
+	if len(res.GetFlags()) == 0 {
+		t.Error("no flags reported, want the flags of the test binary")
+	}
+
+	servers, err := channelzpb.NewChannelzClient(dial(t, addr)).GetServers(ctx, &channelzpb.GetServersRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers.GetServer()) == 0 {
+		t.Error("channelz reports no server")
+	}
+}
+
+func TestPortCredentials(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	if _, _, err := admin.ServePort(lis, admin.PortOptions{Pprof: true}); err == nil {
+		t.Fatal("ServePort with pprof and no credentials succeeded")
+	}
+	public, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer public.Close()
+	if _, _, err := admin.ServePort(public, admin.PortOptions{}); err == nil {
+		t.Fatal("ServePort on every interface without credentials succeeded")
+	}
+	keys, err := auth.LoadAPIKeyStore("../config/api-keys.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := servePort(t, admin.PortOptions{
+		Diagnostics:   admin.NewDiagnostics(nil),
+		Authenticator: auth.NewAuthenticator(nil, keys),
+		Pprof:         true,
+	})
+	c := adminpb.NewDiagnosticsAPIClient(dial(t, addr))
+	for _, tc := range []struct {
+		key      string
+		wantCode codes.Code
+		wantHTTP int
+	}{
+		{"", codes.Unauthenticated, http.StatusUnauthorized},
+		{readerKey, codes.PermissionDenied, http.StatusForbidden},
+		{adminKey, codes.OK, http.StatusOK},
+	} {
+		ctx := context.Background()
+		if tc.key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, tc.key)
+		}
+		_, err := c.GetDiagnostics(ctx, &adminpb.GetDiagnosticsRequest{})
+		if status.Code(err) != tc.wantCode {
+			t.Errorf("GetDiagnostics with key %q = %v, want %v", tc.key, err, tc.wantCode)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/debug/pprof/cmdline", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.key != "" {
+			req.Header.Set(auth.APIKeyHeader, tc.key)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.wantHTTP {
+			t.Errorf("pprof with key %q = %s, want %d", tc.key, res.Status, tc.wantHTTP)
+		}
+	}
+}
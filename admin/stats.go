@@ -0,0 +1,193 @@
+package admin
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// Tracker is a stats.Handler keeping the open connections of a server and
+// the counters of its methods, reported by DiagnosticsAPI. Install it with
+// grpc.StatsHandler; it sees every call, including the ones the interceptors
+// reject.
+type Tracker struct {
+	mu      sync.Mutex
+	conns   map[*connStats]struct{}
+	methods map[string]*methodStats
+}
+
+// connStats is attached to the context of a connection and of its calls
+type connStats struct {
+	remote, local string
+	established   time.Time
+	active        int64
+	started       int64
+}
+
+type methodStats struct {
+	started, inFlight int64
+	codes             map[string]int64
+	received, sent    int64
+}
+
+// rpcStats is attached to the context of a call
+type rpcStats struct {
+	conn   *connStats
+	method *methodStats
+}
+
+// maxMethods bounds the methods counted separately, since clients may call
+// any name; the calls of the others are counted under otherMethods
+const (
+	maxMethods   = 1000
+	otherMethods = "other"
+)
+
+type connKey struct{}
+type rpcKey struct{}
+
+// NewTracker returns a Tracker without connections
+func NewTracker() *Tracker {
+	return &Tracker{conns: map[*connStats]struct{}{}, methods: map[string]*methodStats{}}
+}
+
+func (t *Tracker) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connKey{}, &connStats{remote: addr(info.RemoteAddr), local: addr(info.LocalAddr)})
+}
+
+func (t *Tracker) HandleConn(ctx context.Context, s stats.ConnStats) {
+	c, ok := ctx.Value(connKey{}).(*connStats)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch s.(type) {
+	case *stats.ConnBegin:
+		c.established = time.Now()
+		t.conns[c] = struct{}{}
+	case *stats.ConnEnd:
+		delete(t.conns, c)
+	}
+}
+
+func (t *Tracker) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	c, _ := ctx.Value(connKey{}).(*connStats)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	name := info.FullMethodName
+	if _, ok := t.methods[name]; !ok && len(t.methods) >= maxMethods {
+		name = otherMethods
+	}
+	m, ok := t.methods[name]
+	if !ok {
+		m = &methodStats{codes: map[string]int64{}}
+		t.methods[name] = m
+	}
+	return context.WithValue(ctx, rpcKey{}, &rpcStats{conn: c, method: m})
+}
+
+func (t *Tracker) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	r, ok := ctx.Value(rpcKey{}).(*rpcStats)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch s := s.(type) {
+	case *stats.Begin:
+		r.method.started++
+		r.method.inFlight++
+		if r.conn != nil {
+			r.conn.started++
+			r.conn.active++
+		}
+	case *stats.InPayload:
+		r.method.received++
+	case *stats.OutPayload:
+		r.method.sent++
+	case *stats.End:
+		r.method.inFlight--
+		r.method.codes[status.Code(s.Error).String()]++
+		if r.conn != nil {
+			r.conn.active--
+		}
+	}
+}
+
+// ConnectionInfo describes an open connection
+type ConnectionInfo struct {
+	RemoteAddr, LocalAddr string
+	Established           time.Time
+	// ActiveStreams counts the calls in progress, StreamsStarted all the
+	// calls started on the connection
+	ActiveStreams  int64
+	StreamsStarted int64
+}
+
+// MethodInfo holds the counters of a method
+type MethodInfo struct {
+	Method   string
+	Started  int64
+	InFlight int64
+	// Codes counts the completed calls by status code, e.g. OK or NotFound as
+	// in the grpc_code label of the metrics
+	Codes            map[string]int64
+	MessagesReceived int64
+	MessagesSent     int64
+}
+
+// Connections returns the open connections, the oldest first
+func (t *Tracker) Connections() []ConnectionInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := make([]ConnectionInfo, 0, len(t.conns))
+	for c := range t.conns {
+		res = append(res, ConnectionInfo{
+			RemoteAddr:     c.remote,
+			LocalAddr:      c.local,
+			Established:    c.established,
+			ActiveStreams:  c.active,
+			StreamsStarted: c.started,
+		})
+	}
+	slices.SortFunc(res, func(a, b ConnectionInfo) int { return a.Established.Compare(b.Established) })
+	return res
+}
+
+// Methods returns the counters of the methods called since the start, by
+// method name
+func (t *Tracker) Methods() []MethodInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := make([]MethodInfo, 0, len(t.methods))
+	for name, m := range t.methods {
+		info := MethodInfo{
+			Method:           name,
+			Started:          m.started,
+			InFlight:         m.inFlight,
+			Codes:            map[string]int64{},
+			MessagesReceived: m.received,
+			MessagesSent:     m.sent,
+		}
+		for code, n := range m.codes {
+			info.Codes[code] = n
+		}
+		res = append(res, info)
+	}
+	slices.SortFunc(res, func(a, b MethodInfo) int { return strings.Compare(a.Method, b.Method) })
+	return res
+}
+
+func addr(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
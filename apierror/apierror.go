@@ -0,0 +1,135 @@
+// Package apierror builds and decodes the errors of the services following
+// the google.rpc error model: a canonical status code, a message for humans
+// and errdetails payloads for programs. The errors of the handlers and of the
+// validation, auth, rbac and ratelimit interceptors carry an ErrorInfo whose
+// reason is stable, so clients can branch on it instead of on the message.
+// Injected faults and the Unavailable errors of a server shutting down are
+// plain status errors.
+package apierror
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domain is the logical name of the service raising the errors, reported in
+// ErrorInfo, e.g. "book-search-service"
+type Domain string
+
+// ServerDomain reports the errors of the interceptors shared by every service,
+// such as authentication and rate limiting, which do not depend on the
+// service called
+const ServerDomain Domain = "grpc-go-course"
+
+// Error returns a status error with the code and message, an ErrorInfo with
+// the reason and metadata, and any further details
+func (d Domain) Error(code codes.Code, reason, msg string, metadata map[string]string, details ...protoadapt.MessageV1) error {
+	info := &errdetails.ErrorInfo{Reason: reason, Domain: string(d), Metadata: metadata}
+	st, err := status.New(code, msg).WithDetails(append([]protoadapt.MessageV1{info}, details...)...)
+	if err != nil {
+		// the details could not be marshaled, keep the code and message
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// NotFound reports a missing resource with codes.NotFound and a ResourceInfo
+// naming it
+func (d Domain) NotFound(reason, resourceType, resourceName, description string) error {
+	msg := fmt.Sprintf("%s %q not found", resourceType, resourceName)
+	return d.Error(codes.NotFound, reason, msg,
+		map[string]string{"resource_type": resourceType, "resource_name": resourceName},
+		&errdetails.ResourceInfo{ResourceType: resourceType, ResourceName: resourceName, Description: description},
+	)
+}
+
+// InvalidArgument reports invalid request fields with codes.InvalidArgument
+// and a BadRequest listing the violations
+func (d Domain) InvalidArgument(reason string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	descriptions := make([]string, len(violations))
+	for i, v := range violations {
+		descriptions[i] = v.GetField() + ": " + v.GetDescription()
+	}
+	msg := "invalid request: " + strings.Join(descriptions, "; ")
+	return d.Error(codes.InvalidArgument, reason, msg, nil,
+		&errdetails.BadRequest{FieldViolations: violations},
+	)
+}
+
+// Violation describes why a request field is invalid
+func Violation(field, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+}
+
+// Reason returns the ErrorInfo reason of err, or "" when it has none
+func Reason(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+// Describe renders err for humans: the code and message, then one line per
+// detail
+func Describe(err error) string {
+	st := status.Convert(err)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", st.Code(), st.Message())
+	for _, d := range st.Details() {
+		b.WriteString("\n  ")
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			fmt.Fprintf(&b, "reason: %s (domain %s)", d.GetReason(), d.GetDomain())
+			keys := make([]string, 0, len(d.GetMetadata()))
+			for k := range d.GetMetadata() {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(&b, " %s=%q", k, d.GetMetadata()[k])
+			}
+		case *errdetails.ResourceInfo:
+			fmt.Fprintf(&b, "resource: %s %q", d.GetResourceType(), d.GetResourceName())
+			if d.GetDescription() != "" {
+				fmt.Fprintf(&b, ": %s", d.GetDescription())
+			}
+		case *errdetails.BadRequest:
+			for i, v := range d.GetFieldViolations() {
+				if i > 0 {
+					b.WriteString("\n  ")
+				}
+				fmt.Fprintf(&b, "invalid field %s: %s", v.GetField(), v.GetDescription())
+			}
+		case *errdetails.RetryInfo:
+			fmt.Fprintf(&b, "retry after: %s", d.GetRetryDelay().AsDuration())
+		case *errdetails.QuotaFailure:
+			for i, v := range d.GetViolations() {
+				if i > 0 {
+					b.WriteString("\n  ")
+				}
+				fmt.Fprintf(&b, "quota exceeded for %s: %s", v.GetSubject(), v.GetDescription())
+			}
+		case *errdetails.PreconditionFailure:
+			for i, v := range d.GetViolations() {
+				if i > 0 {
+					b.WriteString("\n  ")
+				}
+				fmt.Fprintf(&b, "precondition %s %s: %s", v.GetType(), v.GetSubject(), v.GetDescription())
+			}
+		case error:
+			// a detail of a type unknown to this binary
+			fmt.Fprintf(&b, "undecodable detail: %v", d)
+		default:
+			fmt.Fprintf(&b, "%T: %v", d, d)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,212 @@
+// Package bookclient is the Go client of the book search service. It wraps the
+// generated BookSearchAPIClient with the connection defaults of rpcclient
+// (retries on Unavailable, hedged GetBook calls, per-call timeouts, and with
+// Options.CacheSize a cache of GetBook honouring the max-age of the server)
+// and adds helpers returning the streamed books as iterators.
+package bookclient
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookerrors"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/rpcclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultTarget is the address the book search server listens on
+const DefaultTarget = "localhost:8989"
+
+// Service is the book search service: GetBook is the only idempotent unary
+// method, so the only one hedged and cached
+var Service = rpcclient.Service{
+	Name:       "book.BookSearchAPI",
+	Idempotent: []string{"GetBook"},
+}
+
+// Options configure the connection, see rpcclient.Options
+type Options = rpcclient.Options
+
+// Client calls the book search service. The generated methods are available
+// as is, next to the helpers.
+type Client struct {
+	bookpb.BookSearchAPIClient
+	conn *grpc.ClientConn
+}
+
+// New returns a client of the server at target
+func New(target string, o Options) (*Client, error) {
+	conn, err := rpcclient.Dial(target, Service, o)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{BookSearchAPIClient: bookpb.NewBookSearchAPIClient(conn), conn: conn}, nil
+}
+
+// Conn returns the connection of the client, e.g. to call the AdminAPI of the
+// same server
+func (c *Client) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Book returns the book titled title
+func (c *Client) Book(ctx context.Context, title string) (*bookpb.Book, error) {
+	res, err := c.GetBook(ctx, &bookpb.GetBookRequest{Title: title})
+	if err != nil {
+		return nil, err
+	}
+	return res.GetBook(), nil
+}
+
+// AllBooks iterates over the books titled title, as the server streams them.
+// Stopping the iteration cancels the call.
+func (c *Client) AllBooks(ctx context.Context, title string) iter.Seq2[*bookpb.Book, error] {
+	return func(yield func(*bookpb.Book, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := c.GetAllBooks(ctx, &bookpb.GetAllBooksRequest{Title: title})
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for res, err := range rpcclient.Recv(stream.Recv) {
+			if !yield(res.GetBook(), err) {
+				return
+			}
+		}
+	}
+}
+
+// BooksForTitles returns the book of each title, in order. The call fails
+// with NotFound when a title matches no book. When the books are too large
+// to come back in one message, for the server or for the connection, they
+// are streamed again with GetEachBook.
+func (c *Client) BooksForTitles(ctx context.Context, titles ...string) ([]*bookpb.Book, error) {
+	stream, err := c.GetBooksForGivenTitles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	largestSent := 0
+	for _, title := range titles {
+		req := &bookpb.GetBooksForGivenTitlesRequest{Title: title}
+		largestSent = max(largestSent, proto.Size(req))
+		if err := stream.Send(req); err != nil {
+			// the server ended the call, CloseAndRecv returns its status
+			break
+		}
+	}
+	res, err := stream.CloseAndRecv()
+	if tooLarge(err, largestSent) {
+		return c.streamBooksForTitles(ctx, titles)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.GetBook(), nil
+}
+
+// streamBooksForTitles returns the book of each title like BooksForTitles,
+// one message at a time. GetEachBook skips the titles matching no book, they
+// are reported as GetBooksForGivenTitles does.
+func (c *Client) streamBooksForTitles(ctx context.Context, titles []string) ([]*bookpb.Book, error) {
+	var books []*bookpb.Book
+	for book, err := range c.EachBook(ctx, slices.Values(titles)) {
+		if err != nil {
+			return nil, err
+		}
+		if len(books) == len(titles) {
+			return nil, status.Error(codes.Internal, "GetEachBook returned more books than titles")
+		}
+		if book.GetTitle() != titles[len(books)] {
+			return nil, bookerrors.BookNotFound(titles[len(books)])
+		}
+		books = append(books, book)
+	}
+	if len(books) < len(titles) {
+		return nil, bookerrors.BookNotFound(titles[len(books)])
+	}
+	return books, nil
+}
+
+// tooLarge reports whether err rejects a response too large for the server
+// to send, reported with RESPONSE_TOO_LARGE, or for the connection to
+// receive. grpc-go fails the call with the same message when the server
+// receives a request above its own limit, so the receive limit is only
+// blamed when none of the requests, the largest taking largestSent bytes,
+// reached it. Any other ResourceExhausted error, such as a rate limit or an
+// injected fault, is returned as is.
+func tooLarge(err error, largestSent int) bool {
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		return false
+	}
+	if apierror.Reason(err) == bookerrors.ReasonResponseTooLarge {
+		return true
+	}
+	limit := recvLimit(st.Message())
+	return limit > 0 && largestSent < limit
+}
+
+// recvLimit returns the limit reported by a receive size error of grpc-go,
+// 0 when msg is not one. grpc-go reports the limits in the message only, with
+// no error detail to check instead, so the formats of the grpc-go version in
+// go.mod are pinned by TestRecvLimitFormats: it fails once an upgrade changes
+// them, before the fallback silently stops working.
+func recvLimit(msg string) int {
+	var size, limit int
+	for _, format := range []string{
+		"grpc: received message larger than max (%d vs. %d)",
+		// only with the deprecated grpc.Decompressor, which is not used here
+		"grpc: message after decompression larger than max (%d vs. %d)",
+	} {
+		if n, _ := fmt.Sscanf(msg, format, &size, &limit); n == 2 {
+			return limit
+		}
+	}
+	if n, _ := fmt.Sscanf(msg, "grpc: received message after decompression larger than max %d", &limit); n == 1 {
+		return limit
+	}
+	return 0
+}
+
+// EachBook sends the titles and iterates over the books found, as soon as
+// the server answers; titles matching no book are skipped. titles is consumed
+// on another goroutine while the books are received. Stopping the iteration
+// cancels the call.
+func (c *Client) EachBook(ctx context.Context, titles iter.Seq[string]) iter.Seq2[*bookpb.Book, error] {
+	return func(yield func(*bookpb.Book, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := c.GetEachBook(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		go func() {
+			for title := range titles {
+				if err := stream.Send(&bookpb.GetEachBookRequest{Title: title}); err != nil {
+					// the call ended, Recv returns its status
+					return
+				}
+			}
+			stream.CloseSend()
+		}()
+		for res, err := range rpcclient.Recv(stream.Recv) {
+			if !yield(res.GetBook(), err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package bookclient
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookerrors"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/booksearch"
+	"github.com/vpulimamidi/grpc-go-course/compression"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBooksForTitlesFallback(t *testing.T) {
+	// two books take more than 200 bytes, too much for the server of one
+	// client and for the connection of the other
+	limits := map[string]testharness.Options{
+		"server limit":     {MaxResponseSize: 200},
+		"connection limit": {DialOptions: []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(200))}},
+	}
+	for name, o := range limits {
+		t.Run(name, func(t *testing.T) {
+			h := testharness.Start(t, o)
+			c := &Client{BookSearchAPIClient: bookpb.NewBookSearchAPIClient(h.BookConn), conn: h.BookConn}
+
+			books, err := c.BooksForTitles(context.Background(), "Java", "Domain Driven Design")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var authors []string
+			for _, b := range books {
+				authors = append(authors, b.GetAuthor())
+			}
+			if want := []string{"Herbert Schildt", "Eric Evans"}; !slices.Equal(authors, want) {
+				t.Errorf("got authors %q, want %q", authors, want)
+			}
+
+			for _, titles := range [][]string{
+				{"Java", "Unknown", "Domain Driven Design"},
+				{"Java", "Domain Driven Design", "Unknown"},
+			} {
+				_, err = c.BooksForTitles(context.Background(), titles...)
+				if status.Code(err) != codes.NotFound || apierror.Reason(err) != bookerrors.ReasonBookNotFound {
+					t.Errorf("%q: got %v, want NotFound", titles, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		err         error
+		largestSent int
+		want        bool
+	}{
+		{"response too large", bookerrors.Domain.Error(codes.ResourceExhausted, bookerrors.ReasonResponseTooLarge, "too large", nil), 10, true},
+		{"receive limit of the client", status.Error(codes.ResourceExhausted, "grpc: received message larger than max (237 vs. 200)"), 10, true},
+		{"decompressed above the limit of the client", status.Error(codes.ResourceExhausted, "grpc: received message after decompression larger than max 200"), 10, true},
+		{"request above the limit of the server", status.Error(codes.ResourceExhausted, "grpc: received message larger than max (237 vs. 200)"), 237, false},
+		{"injected fault", status.Error(codes.ResourceExhausted, "chaos: injected fault"), 10, false},
+		{"rate limit", apierror.ServerDomain.Error(codes.ResourceExhausted, "RATE_LIMITED", "rate limit exceeded", nil), 10, false},
+		{"other code", status.Error(codes.Unavailable, "grpc: received message larger than max (237 vs. 200)"), 10, false},
+	} {
+		if got := tooLarge(tc.err, tc.largestSent); got != tc.want {
+			t.Errorf("%s: tooLarge(%v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+// TestRecvLimitFormats pins the receive size errors of grpc-go parsed by
+// recvLimit, raised here by grpc-go itself
+func TestRecvLimitFormats(t *testing.T) {
+	const limit = 200
+	// a response much larger than the limit, that gzip makes much smaller
+	books := []booksearch.Book{{Title: "Java", Author: strings.Repeat("Herbert Schildt ", 100), ISBN: "978-1260440232"}}
+	for _, tc := range []struct {
+		name string
+		o    testharness.Options
+	}{
+		{"response above the client limit", testharness.Options{
+			Books:       books,
+			DialOptions: []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(limit))},
+		}},
+		{"compressed response above the client limit once decompressed", testharness.Options{
+			Books:             books,
+			UnaryInterceptors: []grpc.UnaryServerInterceptor{compression.UnaryServerInterceptor(compression.Gzip)},
+			DialOptions:       []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(limit))},
+		}},
+		// the same message for a request, which tooLarge tells apart with
+		// the size of the requests
+		{"request above the server limit", testharness.Options{
+			Books:         books,
+			ServerOptions: []grpc.ServerOption{grpc.MaxRecvMsgSize(limit)},
+		}},
+	} {
+		h := testharness.Start(t, tc.o)
+		title := "Java"
+		if tc.o.ServerOptions != nil {
+			title = strings.Repeat("Java ", 40)
+		}
+		_, err := h.Books.GetBook(context.Background(), &bookpb.GetBookRequest{Title: title})
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("%s: got %v, want ResourceExhausted", tc.name, err)
+		}
+		if got := recvLimit(status.Convert(err).Message()); got != limit {
+			t.Errorf("%s: recvLimit(%q) = %d, want %d: the grpc-go message changed, update recvLimit", tc.name, status.Convert(err).Message(), got, limit)
+		}
+	}
+}
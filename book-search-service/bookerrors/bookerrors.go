@@ -0,0 +1,22 @@
+// Package bookerrors holds the error domain and reasons of the book search
+// service, shared by the server and its clients so they cannot drift apart.
+package bookerrors
+
+import "github.com/vpulimamidi/grpc-go-course/apierror"
+
+const (
+	// Domain is reported in the ErrorInfo of the errors of the handlers
+	Domain apierror.Domain = "book-search-service"
+	// ReasonBookNotFound is the ErrorInfo reason of a title matching no book
+	ReasonBookNotFound = "BOOK_NOT_FOUND"
+	// ReasonResponseTooLarge is the ErrorInfo reason of a
+	// GetBooksForGivenTitles result above the response size limit of the
+	// server. Its metadata names the streaming method returning the same
+	// books.
+	ReasonResponseTooLarge = "RESPONSE_TOO_LARGE"
+)
+
+// BookNotFound reports a title matching no book in the catalog
+func BookNotFound(title string) error {
+	return Domain.NotFound(ReasonBookNotFound, "book.Book", title, "no book in the catalog has this title")
+}
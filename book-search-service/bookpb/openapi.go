@@ -0,0 +1,8 @@
+package bookpb
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI document of the REST gateway, generated from book.proto
+//
+//go:embed book.swagger.json
+var OpenAPI []byte
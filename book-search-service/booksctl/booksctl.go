@@ -0,0 +1,231 @@
+// booksctl drives BookSearchAPI from the command line.
+//
+// Requests and responses are JSON. Titles can be given as arguments, or whole
+// requests with -d; the streaming commands read newline delimited JSON
+// requests from stdin when no title is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookclient"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/cli"
+	"google.golang.org/protobuf/proto"
+)
+
+const usageText = `Usage: booksctl [flags] <command> [arguments]
+
+Commands:
+  get [-d json] <title>      GetBook: find one book by title
+  all [-d json] <title>      GetAllBooks: stream every book with the title
+  titles [title...]          GetBooksForGivenTitles: stream titles, receive all the books at once
+  each [title...]            GetEachBook: stream titles, receive each book as it is found
+
+Admin commands, served on the admin port of the server, e.g. -target localhost:8992:
+  quota [-client c] [-method m]
+                             AdminAPI.GetQuotaUsage: show the daily quota counters
+  chaos [-d json] [on|off]   AdminAPI.GetChaos/SetChaos: show, replace or toggle the injected faults
+  diag                       DiagnosticsAPI.GetDiagnostics: show the connections, method counters,
+                             flags, build and runtime
+
+titles and each read newline delimited JSON requests from stdin when no
+title is given, e.g.
+  echo '{"title":"Java"} {"title":"Domain Driven Design"}' | booksctl each
+
+Flags:
+`
+
+var conn cli.ConnFlags
+
+func main() {
+	conn.Register(flag.CommandLine, bookclient.DefaultTarget)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usageText)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c, err := bookclient.New(conn.Target, conn.Options())
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer c.Close()
+
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "get":
+		err = getBook(c, args)
+	case "all":
+		err = getAllBooks(c, args)
+	case "titles":
+		err = getBooksForGivenTitles(c, args)
+	case "each":
+		err = getEachBook(c, args)
+	case "quota":
+		err = conn.QuotaUsage(c.Conn(), args)
+	case "chaos":
+		err = conn.Chaos(c.Conn(), args)
+	case "diag":
+		err = conn.Diagnostics(c.Conn())
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// parseTitleRequest fills req from the -d flag or from a single title argument
+func parseTitleRequest(name string, args []string, req proto.Message, setTitle func(string)) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	data := fs.String("d", "", "Request as JSON")
+	fs.Parse(args)
+	switch {
+	case *data != "":
+		return cli.Unmarshal(*data, req)
+	case fs.NArg() == 1:
+		setTitle(fs.Arg(0))
+		return nil
+	default:
+		return fmt.Errorf("%s needs a title or a -d JSON request", name)
+	}
+}
+
+func getBook(c bookpb.BookSearchAPIClient, args []string) error {
+	req := &bookpb.GetBookRequest{}
+	if err := parseTitleRequest("get", args, req, func(title string) { req.Title = title }); err != nil {
+		return err
+	}
+	ctx, cancel := conn.Context()
+	defer cancel()
+	res, err := c.GetBook(ctx, req)
+	if err != nil {
+		return err
+	}
+	return conn.Print(res)
+}
+
+func getAllBooks(c bookpb.BookSearchAPIClient, args []string) error {
+	req := &bookpb.GetAllBooksRequest{}
+	if err := parseTitleRequest("all", args, req, func(title string) { req.Title = title }); err != nil {
+		return err
+	}
+	ctx, cancel := conn.Context()
+	defer cancel()
+	stream, err := c.GetAllBooks(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := conn.Print(res); err != nil {
+			return err
+		}
+	}
+}
+
+func getBooksForGivenTitles(c bookpb.BookSearchAPIClient, args []string) error {
+	ctx, cancel := conn.Context()
+	defer cancel()
+	stream, err := c.GetBooksForGivenTitles(ctx)
+	if err != nil {
+		return err
+	}
+	err = forEachRequest(args,
+		func() proto.Message { return &bookpb.GetBooksForGivenTitlesRequest{} },
+		func(title string) proto.Message { return &bookpb.GetBooksForGivenTitlesRequest{Title: title} },
+		func(req proto.Message) error { return stream.Send(req.(*bookpb.GetBooksForGivenTitlesRequest)) },
+	)
+	if err != nil {
+		return err
+	}
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	return conn.Print(res)
+}
+
+func getEachBook(c bookpb.BookSearchAPIClient, args []string) error {
+	ctx, cancel := conn.Context()
+	defer cancel()
+	stream, err := c.GetEachBook(ctx)
+	if err != nil {
+		return err
+	}
+	sendErr := make(chan error, 1)
+	go func() {
+		err := forEachRequest(args,
+			func() proto.Message { return &bookpb.GetEachBookRequest{} },
+			func(title string) proto.Message { return &bookpb.GetEachBookRequest{Title: title} },
+			func(req proto.Message) error { return stream.Send(req.(*bookpb.GetEachBookRequest)) },
+		)
+		if err != nil {
+			sendErr <- err
+			cancel()
+			return
+		}
+		sendErr <- stream.CloseSend()
+	}()
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return <-sendErr
+		}
+		if err != nil {
+			select {
+			case serr := <-sendErr:
+				if serr != nil {
+					return serr
+				}
+			default:
+			}
+			return err
+		}
+		if err := conn.Print(res); err != nil {
+			return err
+		}
+	}
+}
+
+// forEachRequest sends one request per title argument, or one per JSON
+// message read from stdin when there is no argument
+func forEachRequest(titles []string, empty func() proto.Message, fromTitle func(string) proto.Message, send func(proto.Message) error) error {
+	if len(titles) > 0 {
+		for _, title := range titles {
+			if err := send(fromTitle(title)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	dec := cli.NewDecoder(os.Stdin)
+	for {
+		req := empty()
+		err := dec.Next(req)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := send(req); err != nil {
+			return err
+		}
+	}
+}
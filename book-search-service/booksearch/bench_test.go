@@ -0,0 +1,119 @@
+package booksearch_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/booksearch"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+)
+
+// The benchmarks call the handlers through the in-process harness, so they
+// include the gRPC stack and the validation interceptors but no network.
+// Catalogs of several sizes show the cost of the linear title lookups:
+//
+//	go test -bench . -benchmem ./book-search-service/booksearch
+
+// catalog returns the sample books followed by n-4 other books, the titles
+// looked up coming last
+func catalog(n int) []booksearch.Book {
+	var books []booksearch.Book
+	for i := 0; i < n-4; i++ {
+		books = append(books, booksearch.Book{Title: fmt.Sprintf("Book %d", i), Author: fmt.Sprintf("Author %d", i)})
+	}
+	return append(books, booksearch.SampleBooks()...)
+}
+
+var catalogSizes = []int{4, 1000}
+
+func BenchmarkGetBook(b *testing.B) {
+	for _, size := range catalogSizes {
+		b.Run(fmt.Sprintf("books=%d", size), func(b *testing.B) {
+			h := testharness.Start(b, testharness.Options{Books: catalog(size)})
+			req := &bookpb.GetBookRequest{Title: "Java"}
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := h.Books.GetBook(context.Background(), req); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkGetAllBooks(b *testing.B) {
+	for _, size := range catalogSizes {
+		b.Run(fmt.Sprintf("books=%d", size), func(b *testing.B) {
+			h := testharness.Start(b, testharness.Options{Books: catalog(size)})
+			req := &bookpb.GetAllBooksRequest{Title: "Java"}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				stream, err := h.Books.GetAllBooks(context.Background(), req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for {
+					if _, err := stream.Recv(); err == io.EOF {
+						break
+					} else if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetBooksForGivenTitles(b *testing.B) {
+	for _, titles := range []int{1, 10, 50} {
+		b.Run(fmt.Sprintf("titles=%d", titles), func(b *testing.B) {
+			h := testharness.Start(b, testharness.Options{})
+			req := &bookpb.GetBooksForGivenTitlesRequest{Title: "Java"}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				stream, err := h.Books.GetBooksForGivenTitles(context.Background())
+				if err != nil {
+					b.Fatal(err)
+				}
+				for j := 0; j < titles; j++ {
+					if err := stream.Send(req); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err := stream.CloseAndRecv(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetEachBook measures a round trip on a long-lived bidirectional
+// stream: one title sent, one book received
+func BenchmarkGetEachBook(b *testing.B) {
+	h := testharness.Start(b, testharness.Options{})
+	stream, err := h.Books.GetEachBook(context.Background())
+	if err != nil {
+		b.Fatal(err)
+	}
+	req := &bookpb.GetEachBookRequest{Title: "Java"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := stream.Send(req); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	stream.CloseSend()
+	stream.Recv()
+}
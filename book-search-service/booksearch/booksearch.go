@@ -0,0 +1,187 @@
+// Package booksearch implements BookSearchAPI, the service served by the book
+// search server, over a catalog Store. It is a package of its own so the
+// handlers can be served in-process, e.g. by the testharness package.
+package booksearch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookerrors"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ServiceName is the fully qualified name of the service
+const ServiceName = "book.BookSearchAPI"
+
+var searchMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "book_search_misses_total",
+	Help: "Number of title searches that matched no book.",
+}, []string{"grpc_method"})
+
+// Server implements bookpb.BookSearchAPIServer
+type Server struct {
+	bookpb.UnimplementedBookSearchAPIServer
+
+	// Store holds the books served
+	Store *Store
+	// StreamInterval is the pause between the books streamed by GetAllBooks
+	StreamInterval time.Duration
+	// MaxResponseSize, when positive, is the largest GetBooksForGivenTitles
+	// response sent, in bytes; larger results fail with
+	// bookerrors.ReasonResponseTooLarge so the client can stream them with GetEachBook
+	MaxResponseSize int
+	// Cache, when set, answers the title lookups in front of Store, and
+	// GetBook tells the clients how long they may cache the book
+	Cache *Cache
+}
+
+// Collectors returns the Prometheus metrics of the handlers and of the
+// catalog size
+func (s *Server) Collectors() []prometheus.Collector {
+	catalogSize := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "book_catalog_size",
+		Help: "Number of books in the catalog.",
+	}, func() float64 {
+		return float64(len(s.Store.Books()))
+	})
+	collectors := []prometheus.Collector{catalogSize, searchMisses}
+	if s.Cache != nil {
+		collectors = append(collectors, s.Cache.collectors()...)
+	}
+	return collectors
+}
+
+// booksByTitle returns the books titled title, and how long the answer may
+// be cached
+func (s *Server) booksByTitle(title string) ([]Book, time.Duration) {
+	if s.Cache != nil {
+		return s.Cache.BooksByTitle(title)
+	}
+	return s.Store.allBooksByTitle(title), 0
+}
+
+// bookByTitle returns the first book titled title, nil if there is none
+func (s *Server) bookByTitle(title string) *Book {
+	if s.Cache == nil {
+		return s.Store.bookByTitle(title)
+	}
+	books, _ := s.Cache.BooksByTitle(title)
+	if len(books) == 0 {
+		return nil
+	}
+	return &books[0]
+}
+
+func (s *Server) GetBook(ctx context.Context, req *bookpb.GetBookRequest) (*bookpb.GetBookResponse, error) {
+	books, ttl := s.booksByTitle(req.GetTitle())
+	if len(books) > 0 {
+		if s.Cache != nil {
+			// the clients may keep the book as long as the server does
+			grpc.SetHeader(ctx, cache.MaxAge(ttl))
+		}
+		response := books[0].Proto()
+		return &bookpb.GetBookResponse{
+			Book: response,
+		}, nil
+	}
+	searchMisses.WithLabelValues("GetBook").Inc()
+	return nil, bookerrors.BookNotFound(req.GetTitle())
+}
+
+func (s *Server) GetAllBooks(req *bookpb.GetAllBooksRequest, stream bookpb.BookSearchAPI_GetAllBooksServer) error {
+	books, _ := s.booksByTitle(req.GetTitle())
+	if len(books) == 0 {
+		searchMisses.WithLabelValues("GetAllBooks").Inc()
+	}
+	if books != nil {
+		for i := 0; i < len(books); i++ {
+			book := books[i]
+			result := book.Proto()
+			err := stream.Send(&bookpb.GetAllBooksResponse{
+				Book: result,
+			})
+			if err != nil {
+				return err
+			}
+			// Pause between the books, unless the call is canceled or the server is shutting down
+			select {
+			case <-stream.Context().Done():
+				return status.FromContextError(stream.Context().Err()).Err()
+			case <-time.After(s.StreamInterval):
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) GetBooksForGivenTitles(stream bookpb.BookSearchAPI_GetBooksForGivenTitlesServer) error {
+	var books []*bookpb.Book
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			// we have finished reading the client stream
+			res := &bookpb.GetBooksForGivenTitlesResponse{
+				Book: books,
+			}
+			if size := proto.Size(res); s.MaxResponseSize > 0 && size > s.MaxResponseSize {
+				return responseTooLarge(size, s.MaxResponseSize)
+			}
+			return stream.SendAndClose(res)
+		}
+		if err != nil {
+			return err
+		}
+		book := s.bookByTitle(req.GetTitle())
+		if book == nil {
+			searchMisses.WithLabelValues("GetBooksForGivenTitles").Inc()
+			return bookerrors.BookNotFound(req.GetTitle())
+		}
+		books = append(books, book.Proto())
+	}
+}
+
+func (s *Server) GetEachBook(stream bookpb.BookSearchAPI_GetEachBookServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+		book := s.bookByTitle(req.GetTitle())
+		if book == nil {
+			searchMisses.WithLabelValues("GetEachBook").Inc()
+			continue
+		}
+		response := book.Proto()
+		sendError := stream.Send(&bookpb.GetEachBookResponse{
+			Book: response,
+		})
+		if sendError != nil {
+			return sendError
+		}
+	}
+}
+
+// responseTooLarge reports a GetBooksForGivenTitles result too large to be
+// sent at once
+func responseTooLarge(size, limit int) error {
+	msg := fmt.Sprintf("the books found take %d bytes, above the limit of %d: stream them with GetEachBook", size, limit)
+	return bookerrors.Domain.Error(codes.ResourceExhausted, bookerrors.ReasonResponseTooLarge, msg, map[string]string{
+		"size":             strconv.Itoa(size),
+		"limit":            strconv.Itoa(limit),
+		"streaming_method": "/" + ServiceName + "/GetEachBook",
+	})
+}
@@ -0,0 +1,341 @@
+package booksearch_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookerrors"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/booksearch"
+	"github.com/vpulimamidi/grpc-go-course/cache"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/validation"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// checkError fails the test unless err has the code and ErrorInfo reason
+// wanted, or is nil when code is codes.OK
+func checkError(t *testing.T, err error, code codes.Code, reason string) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Fatalf("got code %v (%v), want %v", got, err, code)
+	}
+	if got := apierror.Reason(err); got != reason {
+		t.Errorf("got reason %q, want %q", got, reason)
+	}
+}
+
+func authors(books []*bookpb.Book) []string {
+	var names []string
+	for _, b := range books {
+		names = append(names, b.GetAuthor())
+	}
+	return names
+}
+
+func TestGetBook(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{})
+	tests := []struct {
+		name       string
+		title      string
+		wantAuthor string
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{name: "found", title: "Domain Driven Design", wantAuthor: "Eric Evans"},
+		{name: "first of several", title: "Java", wantAuthor: "Herbert Schildt"},
+		{name: "unknown title", title: "The Art of Computer Programming", wantCode: codes.NotFound, wantReason: bookerrors.ReasonBookNotFound},
+		{name: "empty title", title: "", wantCode: codes.InvalidArgument, wantReason: validation.ReasonInvalidRequest},
+		{name: "control character", title: "Java\n", wantCode: codes.InvalidArgument, wantReason: validation.ReasonInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := h.Books.GetBook(context.Background(), &bookpb.GetBookRequest{Title: tt.title})
+			checkError(t, err, tt.wantCode, tt.wantReason)
+			if got := res.GetBook().GetAuthor(); got != tt.wantAuthor {
+				t.Errorf("got author %q, want %q", got, tt.wantAuthor)
+			}
+		})
+	}
+}
+
+func TestGetAllBooks(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{})
+	tests := []struct {
+		name        string
+		title       string
+		wantAuthors []string
+		wantCode    codes.Code
+	}{
+		{name: "several books", title: "Java", wantAuthors: []string{"Herbert Schildt", "Kathy Sierra", "Joshua Bloch"}},
+		{name: "one book", title: "Domain Driven Design", wantAuthors: []string{"Eric Evans"}},
+		{name: "no book", title: "The Art of Computer Programming"},
+		{name: "empty title", title: "", wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := h.Books.GetAllBooks(context.Background(), &bookpb.GetAllBooksRequest{Title: tt.title})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var books []*bookpb.Book
+			for {
+				res, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					checkError(t, err, tt.wantCode, validation.ReasonInvalidRequest)
+					return
+				}
+				books = append(books, res.GetBook())
+			}
+			if tt.wantCode != codes.OK {
+				t.Fatalf("got no error, want %v", tt.wantCode)
+			}
+			if got := authors(books); !slices.Equal(got, tt.wantAuthors) {
+				t.Errorf("got authors %q, want %q", got, tt.wantAuthors)
+			}
+		})
+	}
+}
+
+func TestGetAllBooksDeadline(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{StreamInterval: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	stream, err := h.Books.GetAllBooks(ctx, &bookpb.GetAllBooksRequest{Title: "Java"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first book is sent right away, the second one after the deadline
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("first book: %v", err)
+	}
+	_, err = stream.Recv()
+	checkError(t, err, codes.DeadlineExceeded, "")
+}
+
+func TestGetBooksForGivenTitles(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{})
+	// two books take more than 200 bytes
+	limited := testharness.Start(t, testharness.Options{MaxResponseSize: 200})
+	tests := []struct {
+		name        string
+		limited     bool
+		titles      []string
+		wantAuthors []string
+		wantCode    codes.Code
+		wantReason  string
+	}{
+		{name: "all found", titles: []string{"Java", "Domain Driven Design"}, wantAuthors: []string{"Herbert Schildt", "Eric Evans"}},
+		{name: "no title"},
+		{name: "unknown title", titles: []string{"Java", "The Art of Computer Programming"}, wantCode: codes.NotFound, wantReason: bookerrors.ReasonBookNotFound},
+		{name: "empty title", titles: []string{"Java", ""}, wantCode: codes.InvalidArgument, wantReason: validation.ReasonInvalidRequest},
+		{name: "below the size limit", limited: true, titles: []string{"Java"}, wantAuthors: []string{"Herbert Schildt"}},
+		{name: "above the size limit", limited: true, titles: []string{"Java", "Domain Driven Design"}, wantCode: codes.ResourceExhausted, wantReason: bookerrors.ReasonResponseTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := h.Books
+			if tt.limited {
+				c = limited.Books
+			}
+			stream, err := c.GetBooksForGivenTitles(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, title := range tt.titles {
+				if err := stream.Send(&bookpb.GetBooksForGivenTitlesRequest{Title: title}); err != nil {
+					// the server ended the call, CloseAndRecv returns its status
+					break
+				}
+			}
+			res, err := stream.CloseAndRecv()
+			checkError(t, err, tt.wantCode, tt.wantReason)
+			if got := authors(res.GetBook()); !slices.Equal(got, tt.wantAuthors) {
+				t.Errorf("got authors %q, want %q", got, tt.wantAuthors)
+			}
+		})
+	}
+}
+
+func TestGetEachBook(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{})
+	tests := []struct {
+		name        string
+		titles      []string
+		wantAuthors []string
+		wantCode    codes.Code
+	}{
+		{name: "unknown titles skipped", titles: []string{"Domain Driven Design", "The Art of Computer Programming", "Java"}, wantAuthors: []string{"Eric Evans", "Herbert Schildt"}},
+		{name: "no title"},
+		{name: "empty title", titles: []string{"Java", ""}, wantAuthors: []string{"Herbert Schildt"}, wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := h.Books.GetEachBook(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var books []*bookpb.Book
+			// send and receive in turn: every known title gets its book
+			// before the next title is sent
+			for _, title := range tt.titles {
+				if err := stream.Send(&bookpb.GetEachBookRequest{Title: title}); err != nil {
+					break
+				}
+				if slices.ContainsFunc(booksearch.SampleBooks(), func(b booksearch.Book) bool { return b.Title == title }) {
+					res, err := stream.Recv()
+					if err != nil {
+						t.Fatalf("receiving the book of %q: %v", title, err)
+					}
+					books = append(books, res.GetBook())
+				}
+			}
+			stream.CloseSend()
+			_, err = stream.Recv()
+			if err == io.EOF {
+				err = nil
+			}
+			checkError(t, err, tt.wantCode, reasonOf(tt.wantCode))
+			if got := authors(books); !slices.Equal(got, tt.wantAuthors) {
+				t.Errorf("got authors %q, want %q", got, tt.wantAuthors)
+			}
+		})
+	}
+}
+
+// reasonOf returns the reason expected along with code in these tests
+func reasonOf(code codes.Code) string {
+	if code == codes.InvalidArgument {
+		return validation.ReasonInvalidRequest
+	}
+	return ""
+}
+
+func TestSeededCatalog(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{Books: []booksearch.Book{
+		{Title: "Refactoring", Author: "Martin Fowler", ISBN: "978-0134757599"},
+	}})
+	res, err := h.Books.GetBook(context.Background(), &bookpb.GetBookRequest{Title: "Refactoring"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.GetBook().GetIsbn(); got != "978-0134757599" {
+		t.Errorf("got ISBN %q, want 978-0134757599", got)
+	}
+	_, err = h.Books.GetBook(context.Background(), &bookpb.GetBookRequest{Title: "Java"})
+	checkError(t, err, codes.NotFound, bookerrors.ReasonBookNotFound)
+}
+
+func TestFileStoreRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog string
+		want    []string
+		wantErr bool
+	}{
+		{name: "valid", catalog: `[{"title": "Java", "author": "Herbert Schildt", "isbn": "978-1260463415"}]`, want: []string{"Java"}},
+		{name: "not JSON", catalog: `not json`, wantErr: true},
+		{name: "missing author", catalog: `[{"title": "Java"}]`, wantErr: true},
+		{name: "invalid ISBN", catalog: `[{"title": "Java", "author": "Herbert Schildt", "isbn": "978-1260463416"}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "books.json")
+			if err := os.WriteFile(path, []byte(tt.catalog), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			store := booksearch.NewFileStore(path)
+			err := store.Refresh(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			var titles []string
+			for _, b := range store.Books() {
+				titles = append(titles, b.Title)
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("got titles %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "books.json")
+	write := func(author string, modTime time.Time) {
+		t.Helper()
+		catalog := `[{"title": "Java", "author": "` + author + `"}]`
+		if err := os.WriteFile(path, []byte(catalog), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lookup := func(c *booksearch.Cache, title string) []string {
+		t.Helper()
+		books, _ := c.BooksByTitle(title)
+		var names []string
+		for _, b := range books {
+			names = append(names, b.Author)
+		}
+		return names
+	}
+
+	write("Herbert Schildt", time.Now().Add(-time.Hour))
+	store := booksearch.NewFileStore(path)
+	if err := store.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c := booksearch.NewCache(store, 10, time.Minute)
+	for i := 0; i < 3; i++ {
+		if got := lookup(c, "Java"); !slices.Equal(got, []string{"Herbert Schildt"}) {
+			t.Fatalf("got %q", got)
+		}
+	}
+	if s := c.Stats(); s.Hits != 2 || s.Misses != 1 {
+		t.Errorf("got %d hits and %d misses, want 2 and 1", s.Hits, s.Misses)
+	}
+
+	// a new catalog empties the cache
+	write("Kathy Sierra", time.Now())
+	if err := store.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := lookup(c, "Java"); !slices.Equal(got, []string{"Kathy Sierra"}) {
+		t.Errorf("after the catalog changed, got %q", got)
+	}
+}
+
+func TestGetBookCacheHint(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{CacheSize: 10, CacheTTL: time.Minute})
+	var header metadata.MD
+	if _, err := h.Books.GetBook(context.Background(), &bookpb.GetBookRequest{Title: "Java"}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.ParseMaxAge(header); got <= 0 || got > time.Minute {
+		t.Errorf("got max-age %v (%q), want up to 1m", got, header.Get(cache.ControlKey))
+	}
+
+	// no hint without a cache on the server
+	h = testharness.Start(t, testharness.Options{})
+	header = nil
+	if _, err := h.Books.GetBook(context.Background(), &bookpb.GetBookRequest{Title: "Java"}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get(cache.ControlKey); got != nil {
+		t.Errorf("got %s %q from a server without a cache", cache.ControlKey, got)
+	}
+}
@@ -0,0 +1,81 @@
+package booksearch
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vpulimamidi/grpc-go-course/cache"
+)
+
+// Cache is a read-through cache of the title lookups of a Store, so the
+// handlers do not scan the catalog on every call. Entries expire after a TTL,
+// the least recently used ones are evicted when the cache is full, and every
+// change of the catalog empties it.
+type Cache struct {
+	store *Store
+	ttl   time.Duration
+	lru   *cache.LRU[string, cachedBooks]
+	// generation is the catalog generation of the entries
+	generation atomic.Uint64
+}
+
+// cachedBooks are the books of a title, in a generation of the catalog
+type cachedBooks struct {
+	books      []Book
+	generation uint64
+}
+
+// NewCache returns a cache of the lookups of store holding up to size titles
+// for ttl each
+func NewCache(store *Store, size int, ttl time.Duration) *Cache {
+	c := &Cache{store: store, ttl: ttl, lru: cache.New[string, cachedBooks](size)}
+	_, gen := store.snapshot()
+	c.generation.Store(gen)
+	return c
+}
+
+// BooksByTitle returns the books titled title, and how long the answer
+// remains in the cache
+func (c *Cache) BooksByTitle(title string) ([]Book, time.Duration) {
+	books, gen := c.store.snapshot()
+	if old := c.generation.Swap(gen); old != gen {
+		c.lru.Purge()
+	}
+	// an entry added by a lookup racing with the purge may belong to the
+	// previous catalog
+	if e, ttl, ok := c.lru.Get(title); ok && e.generation == gen {
+		return e.books, ttl
+	}
+	found := booksByTitle(books, title)
+	c.lru.Add(title, cachedBooks{books: found, generation: gen}, c.ttl)
+	return found, c.ttl
+}
+
+// Stats returns the counters of the cache
+func (c *Cache) Stats() cache.Stats {
+	return c.lru.Stats()
+}
+
+// collectors returns the Prometheus metrics of the cache
+func (c *Cache) collectors() []prometheus.Collector {
+	counter := func(name, help string, value func(cache.Stats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(c.lru.Stats()))
+		})
+	}
+	return []prometheus.Collector{
+		counter("book_cache_hits_total", "Number of title lookups answered by the cache.",
+			func(s cache.Stats) uint64 { return s.Hits }),
+		counter("book_cache_misses_total", "Number of title lookups that scanned the catalog.",
+			func(s cache.Stats) uint64 { return s.Misses }),
+		counter("book_cache_evictions_total", "Number of titles evicted from the full cache.",
+			func(s cache.Stats) uint64 { return s.Evictions }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "book_cache_entries",
+			Help: "Number of titles in the cache.",
+		}, func() float64 {
+			return float64(c.lru.Stats().Entries)
+		}),
+	}
+}
@@ -0,0 +1,76 @@
+package booksearch_test
+
+import (
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/booksearch"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// The fuzz targets send arbitrary bytes as the requests of every handler,
+// through the in-process harness and its validation interceptors. The
+// messages of the streaming methods are length-prefixed in the input, see
+// testharness.SplitMessages. A target fails when the server panics, hangs, or
+// answers with a status code the API does not document. The seed corpus is
+// in testdata/fuzz and runs with the other tests; to fuzz a target:
+//
+//	go test -run XXX -fuzz FuzzGetBooksForGivenTitles -fuzztime 1m -fuzzminimizetime 100x ./book-search-service/booksearch
+//
+// The goroutines of gRPC make the coverage of a call vary a little, so the
+// fuzzer finds many inputs interesting; without -fuzzminimizetime it spends
+// most of its time minimizing them.
+
+func FuzzGetBook(f *testing.F) {
+	f.Add(marshal(&bookpb.GetBookRequest{Title: "Java"}))
+	h := testharness.Start(f, testharness.Options{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzCall(t, h, "GetBook", &grpc.StreamDesc{}, [][]byte{data}, &bookpb.GetBookRequest{}, &bookpb.GetBookResponse{}, codes.NotFound)
+	})
+}
+
+func FuzzGetAllBooks(f *testing.F) {
+	f.Add(marshal(&bookpb.GetAllBooksRequest{Title: "Java"}))
+	h := testharness.Start(f, testharness.Options{})
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzCall(t, h, "GetAllBooks", desc, [][]byte{data}, &bookpb.GetAllBooksRequest{}, &bookpb.GetAllBooksResponse{})
+	})
+}
+
+func FuzzGetBooksForGivenTitles(f *testing.F) {
+	f.Add(testharness.JoinMessages(&bookpb.GetBooksForGivenTitlesRequest{Title: "Java"}, &bookpb.GetBooksForGivenTitlesRequest{Title: "Domain Driven Design"}))
+	h := testharness.Start(f, testharness.Options{})
+	desc := &grpc.StreamDesc{ClientStreams: true}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzCall(t, h, "GetBooksForGivenTitles", desc, testharness.SplitMessages(data),
+			&bookpb.GetBooksForGivenTitlesRequest{}, &bookpb.GetBooksForGivenTitlesResponse{}, codes.NotFound)
+	})
+}
+
+func FuzzGetEachBook(f *testing.F) {
+	f.Add(testharness.JoinMessages(&bookpb.GetEachBookRequest{Title: "Java"}, &bookpb.GetEachBookRequest{Title: "Unknown"}))
+	h := testharness.Start(f, testharness.Options{})
+	desc := &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzCall(t, h, "GetEachBook", desc, testharness.SplitMessages(data), &bookpb.GetEachBookRequest{}, &bookpb.GetEachBookResponse{})
+	})
+}
+
+// fuzzCall calls a method of the book search server with the encoded
+// requests, see testharness.FuzzCall
+func fuzzCall(t *testing.T, h *testharness.Harness, method string, desc *grpc.StreamDesc, requests [][]byte, req, res proto.Message, allowed ...codes.Code) {
+	t.Helper()
+	testharness.FuzzCall(t, h.BookConn, "/"+booksearch.ServiceName+"/"+method, desc, requests, req, res, allowed...)
+}
+
+func marshal(m proto.Message) []byte {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
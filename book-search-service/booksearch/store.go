@@ -0,0 +1,194 @@
+package booksearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookerrors"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/validation"
+	"google.golang.org/grpc/status"
+)
+
+// Book is a book of the catalog, as stored in the catalog file
+type Book struct {
+	Title    string  `json:"title"`
+	Subject  string  `json:"subject"`
+	Audience string  `json:"audience"`
+	Author   string  `json:"author"`
+	Price    float32 `json:"price"`
+	ISBN     string  `json:"isbn"`
+}
+
+// Proto returns the book as served by the API
+func (b Book) Proto() *bookpb.Book {
+	return &bookpb.Book{
+		Title:    b.Title,
+		Subject:  b.Subject,
+		Audience: b.Audience,
+		Author:   b.Author,
+		Price:    b.Price,
+		Isbn:     b.ISBN,
+	}
+}
+
+// Store holds the catalog served by the handlers. A store made by NewStore
+// serves fixed books; one made by NewFileStore reloads its file whenever it
+// changes and keeps the last good copy while it cannot be read.
+type Store struct {
+	path string
+
+	mu      sync.RWMutex
+	books   []Book
+	modTime time.Time
+	// generation counts the changes of the catalog, so the caches of its
+	// lookups can tell their entries are stale
+	generation uint64
+}
+
+// NewStore returns a store serving books
+func NewStore(books []Book) *Store {
+	return &Store{books: books}
+}
+
+// NewFileStore returns a store serving the JSON catalog at path, empty until
+// the first successful Refresh
+func NewFileStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Refresh reloads the catalog file if it changed. It returns an error while
+// the file is unavailable or holds an invalid book.
+func (s *Store) Refresh(ctx context.Context) error {
+	if s.path == "" {
+		return nil
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var books []Book
+	if err := json.Unmarshal(data, &books); err != nil {
+		return fmt.Errorf("parsing catalog %s: %w", s.path, err)
+	}
+	for i, b := range books {
+		// the catalog must satisfy the rules of book.Book, or the handlers
+		// would serve books their clients reject
+		if err := validation.Check(bookerrors.Domain, b.Proto()); err != nil {
+			return fmt.Errorf("catalog %s, book %d: %s", s.path, i, status.Convert(err).Message())
+		}
+	}
+	s.mu.Lock()
+	s.books, s.modTime = books, info.ModTime()
+	s.generation++
+	s.mu.Unlock()
+	return nil
+}
+
+// Books returns the catalog
+func (s *Store) Books() []Book {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.books
+}
+
+// snapshot returns the catalog and its generation
+func (s *Store) snapshot() ([]Book, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.books, s.generation
+}
+
+// SampleBooks returns the books served when no catalog file is configured
+func SampleBooks() []Book {
+	books := make([]Book, 4)
+	books[0] = Book{
+		Title:    "Domain Driven Design",
+		Subject:  "Tackling complexity in the heart of Software",
+		Audience: "Software Engineers",
+		Author:   "Eric Evans",
+		Price:    999.0,
+		ISBN:     "978-0321125217",
+	}
+	books[1] = Book{
+		Title:    "Java",
+		Subject:  "Comprehensive guide to the entire Java laguage",
+		Audience: "Software Engineers",
+		Author:   "Herbert Schildt",
+		Price:    999.0,
+		ISBN:     "978-1260463415",
+	}
+	books[2] = Book{
+		Title:    "Java",
+		Subject:  "Head First Java",
+		Audience: "Software Engineers",
+		Author:   "Kathy Sierra",
+		Price:    999.0,
+		ISBN:     "978-0596009205",
+	}
+	books[3] = Book{
+		Title:    "Java",
+		Subject:  "Effective Java",
+		Audience: "Software Engineers",
+		Author:   "Joshua Bloch",
+		Price:    999.0,
+		ISBN:     "978-0134685991",
+	}
+	return books
+}
+
+// Find the book by title and return it
+func (s *Store) bookByTitle(title string) *Book {
+	books := s.Books()
+	for _, book := range books {
+		if book.Title == title {
+			return &book
+		}
+	}
+	return nil
+}
+
+// Find the book by title and return it
+func (s *Store) allBooksByTitle(title string) []Book {
+	return booksByTitle(s.Books(), title)
+}
+
+// booksByTitle returns the books of the catalog titled title
+func booksByTitle(books []Book, title string) []Book {
+	tempBooks := make([]Book, len(books))
+	index := 0
+	for _, book := range books {
+		if book.Title == title {
+			tempBooks[index] = book
+			index++
+		}
+	}
+	matchedBooks := make([]Book, index)
+	copy(matchedBooks, tempBooks)
+	return matchedBooks
+}
+
+// Find the book by Author
+func (s *Store) bookByAuthor(author string) *Book {
+	books := s.Books()
+	for _, book := range books {
+		if book.Author == author {
+			return &book
+		}
+	}
+	return nil
+}
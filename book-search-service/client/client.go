@@ -3,32 +3,73 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"time"
 
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookclient"
 	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/metrics"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/tracing"
 	"google.golang.org/grpc"
 )
 
-const (
-	targetHost = "localhost:8989"
+var (
+	target        = flag.String("target", bookclient.DefaultTarget, "Server address, or several with dns:///, static:/// or file:/// targets")
+	balancer      = flag.String("lb", "", "Spread the calls over the addresses of -target: round_robin or least_request")
+	token         = flag.String("token", "", "Bearer JWT sent with every call")
+	apiKey        = flag.String("api-key", "", "API key sent with every call")
+	metricsAddr   = flag.String("metrics-addr", "", "Address of an HTTP listener serving the client Prometheus /metrics while the examples run")
+	traceExporter = flag.String("trace-exporter", "", "OpenTelemetry span exporter: otlp, stdout or file, empty to disable tracing")
+	traceEndpoint = flag.String("trace-endpoint", "localhost:4317", "OTLP gRPC collector address used by the otlp exporter")
+	traceInsecure = flag.Bool("trace-insecure", true, "Connect to the OTLP collector without TLS")
+	traceFile     = flag.String("trace-file", "traces.json", "File receiving the spans with the file exporter")
+	traceSample   = flag.Float64("trace-sample", 1, "Fraction of new traces that are recorded")
 )
 
 func main() {
+	flag.Parse()
 	fmt.Printf("---This is a book search client---\n")
-	// Create a client connection using target host
-	clientConnection, err := grpc.Dial(targetHost, grpc.WithInsecure())
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: "book-search-client",
+		Exporter:    *traceExporter,
+		Endpoint:    *traceEndpoint,
+		Insecure:    *traceInsecure,
+		File:        *traceFile,
+		SampleRatio: *traceSample,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	opts := bookclient.Options{
+		Token:       *token,
+		APIKey:      *apiKey,
+		Balancer:    *balancer,
+		DialOptions: []grpc.DialOption{tracing.DialOption()},
+	}
+	if *metricsAddr != "" {
+		clientMetrics := metrics.NewClientMetrics()
+		metrics.Serve(*metricsAddr, metrics.NewRegistry(clientMetrics))
+		opts.DialOptions = append(opts.DialOptions,
+			grpc.WithChainUnaryInterceptor(clientMetrics.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(clientMetrics.StreamClientInterceptor()),
+		)
+	}
+	// Create a book search client, retrying the calls while the server is unavailable
+	bookSearchClient, err := bookclient.New(*target, opts)
 	if err != nil {
 		log.Fatalf("Could not connect to server %v", err)
 	}
 	// This code will make sure to close the connection at the end of this main function
-	defer clientConnection.Close()
-	// Create book search client using clientConnection
-	bookSearchClient := bookpb.NewBookSearchAPIClient(clientConnection)
+	defer bookSearchClient.Close()
 	// Example for Unary API call logic
-	doUnaryAPICall(bookSearchClient)
+	doUnaryAPICall(bookSearchClient, "Java")
+	// failure case, the error details name the missing book
+	doUnaryAPICall(bookSearchClient, "The Art of Computer Programming")
 	// Example for Server streaming API call logic
 	doServerStreamingAPICall(bookSearchClient)
 	// Example for Client streaming API call logic
@@ -37,15 +78,18 @@ func main() {
 	doBiDiStreamingAPICall(bookSearchClient)
 }
 
-func doUnaryAPICall(bookSearchClient bookpb.BookSearchAPIClient) {
+func doUnaryAPICall(bookSearchClient bookpb.BookSearchAPIClient, title string) {
 	fmt.Println("\n\n----------- Unary API Call Example - Start -----------")
 	// Build a BookSearchByTitleRequest
 	request := &bookpb.GetBookRequest{
-		Title: "Java",
+		Title: title,
 	}
 	bookSearchResponse, err := bookSearchClient.GetBook(context.Background(), request)
 	if err != nil {
-		log.Printf("Error message: %s", err)
+		log.Printf("Error: %s", apierror.Describe(err))
+		if apierror.Reason(err) == "BOOK_NOT_FOUND" {
+			log.Printf("No book is titled %q", title)
+		}
 	}
 	if bookSearchResponse != nil {
 		log.Printf("Request:  %v\n", request)
@@ -62,7 +106,7 @@ func doServerStreamingAPICall(c bookpb.BookSearchAPIClient) {
 	fmt.Printf("Request: %v", req)
 	resStream, err := c.GetAllBooks(context.Background(), req)
 	if err != nil {
-		log.Fatalf("Error while calling GetAllBooks RPC: %v", err)
+		log.Fatalf("Error while calling GetAllBooks RPC: %s", apierror.Describe(err))
 	}
 
 	for {
@@ -72,7 +116,7 @@ func doServerStreamingAPICall(c bookpb.BookSearchAPIClient) {
 			break
 		}
 		if err != nil {
-			log.Fatalf("Error while reading stream : %v", err)
+			log.Fatalf("Error while reading stream : %s", apierror.Describe(err))
 		}
 		data, _ := json.MarshalIndent(response.Book, "", "    ")
 		fmt.Println("Response: ", string(data))
@@ -93,7 +137,7 @@ func doClientStreamingAPICall(c bookpb.BookSearchAPIClient) {
 	}
 	stream, err := c.GetBooksForGivenTitles(context.Background())
 	if err != nil {
-		log.Fatalf("Error while calling GetTheBooksForGivenTitles: %v", err)
+		log.Fatalf("Error while calling GetTheBooksForGivenTitles: %s", apierror.Describe(err))
 	}
 	// we iterate over our slice and send each message individually
 	for _, req := range requests {
@@ -103,7 +147,7 @@ func doClientStreamingAPICall(c bookpb.BookSearchAPIClient) {
 	}
 	res, err := stream.CloseAndRecv()
 	if err != nil {
-		log.Fatalf("Error while receiving response from GetBooksForGivenTitles: %v", err)
+		log.Fatalf("Error while receiving response from GetBooksForGivenTitles: %s", apierror.Describe(err))
 	}
 	data, _ := json.MarshalIndent(res.Book, "", "    ")
 	// Print formatted JSON
@@ -116,7 +160,7 @@ func doBiDiStreamingAPICall(c bookpb.BookSearchAPIClient) {
 	// Create a stream
 	stream, err := c.GetEachBook(context.Background())
 	if err != nil {
-		log.Fatalf("Error while creating stream: %v", err)
+		log.Fatalf("Error while creating stream: %s", apierror.Describe(err))
 	}
 
 	requests := []*bookpb.GetEachBookRequest{
@@ -147,7 +191,7 @@ func doBiDiStreamingAPICall(c bookpb.BookSearchAPIClient) {
 				break
 			}
 			if err != nil {
-				log.Fatalf("Error while receiving: %v", err)
+				log.Fatalf("Error while receiving: %s", apierror.Describe(err))
 				break
 			}
 			fmt.Println("Received Response:")
@@ -1,2 +1,2 @@
 #!/bin/bash
-protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative bookpb/book.proto
\ No newline at end of file
+protoc -I . -I ../third_party -I ../validation --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative --grpc-gateway_out=. --grpc-gateway_opt=paths=source_relative --openapiv2_out=. bookpb/book.proto
\ No newline at end of file
@@ -20,217 +20,296 @@ package main
 
 import (
 	"context"
-	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
 	"net"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/vpulimamidi/grpc-go-course/admin"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookerrors"
 	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/booksearch"
+	"github.com/vpulimamidi/grpc-go-course/compression"
+	"github.com/vpulimamidi/grpc-go-course/gateway"
+	"github.com/vpulimamidi/grpc-go-course/graceful"
+	"github.com/vpulimamidi/grpc-go-course/healthcheck"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/chaos"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/logging"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/metrics"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/ratelimit"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/rbac"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/recording"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/tracing"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/validation"
+	"github.com/vpulimamidi/grpc-go-course/keepalive"
+	"github.com/vpulimamidi/grpc-go-course/web"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
 )
 
 const (
 	port = ":8989"
+	// serviceName is the fully qualified name reported by the health service
+	serviceName = booksearch.ServiceName
 )
 
-type server struct {
-	bookpb.UnimplementedBookSearchAPIServer
-}
+var (
+	listenAddr     = flag.String("addr", port, "Address of the gRPC listener, e.g. :8979 for a second replica")
+	jwksFile       = flag.String("jwks", "", "JWKS file used to verify bearer tokens (e.g. ../../config/jwks.json)")
+	apiKeysFile    = flag.String("api-keys", "", "YAML file listing the accepted API keys (e.g. ../../config/api-keys.yaml)")
+	rbacPolicy     = flag.String("rbac-policy", "", "YAML policy restricting which roles may call which methods (e.g. ../../config/rbac.yaml)")
+	rateLimits     = flag.String("rate-limits", "", "YAML file with per-method rate limits and daily quotas (e.g. ../../config/ratelimit.yaml)")
+	chaosFile      = flag.String("chaos", "", "YAML file with the faults injected from startup (e.g. ../../config/chaos.yaml), changed at runtime through AdminAPI.SetChaos on the admin listener")
+	recordFile     = flag.String("record", "", "Binary log receiving the calls, messages and statuses, for the replay command; empty to disable")
+	recordMethods  = flag.String("record-methods", "/"+serviceName+"/", "Comma separated methods recorded, or services with a trailing slash")
+	logSample      = flag.Float64("log-sample", 1, "Fraction of successful calls written to the request log, failures are always logged")
+	logPayloads    = flag.Bool("log-payloads", false, "Include unary request and response messages in the request log")
+	logRedact      = flag.String("log-redact", "", "Comma separated proto field names masked in logged payloads (e.g. title,author)")
+	cacheSize      = flag.Int("cache-size", 1000, "Number of titles whose books are cached in front of the catalog, 0 to disable the cache")
+	cacheTTL       = flag.Duration("cache-ttl", 30*time.Second, "How long a title stays cached, also sent to the clients as the max-age of GetBook responses")
+	catalogFile    = flag.String("catalog", "", "JSON file holding the book catalog (e.g. ../../config/books.json), sample books are served when empty")
+	compressors    = flag.String("compression", compression.Zstd+","+compression.Gzip, "Comma separated compressors of the responses, by preference, used when the client accepts one; empty to answer like the request")
+	maxRecvSize    = flag.Int("max-recv-size", 4<<20, "Largest request message accepted, in bytes")
+	maxSendSize    = flag.Int("max-send-size", 4<<20, "Largest response message sent, in bytes; larger GetBooksForGivenTitles results fail with RESPONSE_TOO_LARGE and the clients switch to GetEachBook")
+	keepaliveFile  = flag.String("keepalive", "", "YAML file with the keepalive pings, enforcement policy and connection age limits (e.g. ../../config/keepalive.yaml), the documented defaults when empty")
+	drainTimeout   = flag.Duration("drain-timeout", 30*time.Second, "How long in-flight calls may run after SIGTERM before they are aborted")
+	shutdownDelay  = flag.Duration("shutdown-delay", 5*time.Second, "How long the server keeps serving after SIGTERM with the health service NOT_SERVING, so load balancers stop sending calls, before it drains")
+	healthInterval = flag.Duration("health-interval", 5*time.Second, "How often the dependencies reported by the health service are probed")
+	healthFailures = flag.Int("health-failure-threshold", 1, "How many probes of a dependency must fail in a row before its services report NOT_SERVING")
+	metricsAddr    = flag.String("metrics-addr", ":8990", "Address of the HTTP listener serving Prometheus /metrics, empty to disable")
+	httpAddr       = flag.String("http-addr", ":8991", "Address of the REST/JSON gateway listener, empty to disable")
+	adminAddr      = flag.String("admin-addr", "localhost:8992", "Address of the admin listener serving AdminAPI, channelz, DiagnosticsAPI and, with -pprof, /debug/pprof/; keep it away from the clients, loopback only unless -jwks or -api-keys is set, empty to disable")
+	pprofEnabled   = flag.Bool("pprof", false, "Serve net/http/pprof on the admin listener to callers holding the admin role, needs -jwks or -api-keys")
+	webEnabled     = flag.Bool("web", true, "Also serve gRPC-Web and Connect (JSON and binary) to browsers on the gRPC port")
+	corsOrigins    = flag.String("cors-origins", "", "Comma separated origins allowed to make cross-origin browser calls (e.g. http://localhost:3000), * for any")
+	traceExporter  = flag.String("trace-exporter", "", "OpenTelemetry span exporter: otlp, stdout or file, empty to disable tracing")
+	traceEndpoint  = flag.String("trace-endpoint", "localhost:4317", "OTLP gRPC collector address used by the otlp exporter")
+	traceInsecure  = flag.Bool("trace-insecure", true, "Connect to the OTLP collector without TLS")
+	traceFile      = flag.String("trace-file", "traces.json", "File receiving the spans with the file exporter")
+	traceSample    = flag.Float64("trace-sample", 1, "Fraction of new traces that are recorded")
+)
 
-func (s *server) GetBook(ctx context.Context, req *bookpb.GetBookRequest) (*bookpb.GetBookResponse, error) {
-	fmt.Printf("\nRequest information : %v", req)
-	book := getBookByTitle(req.GetTitle())
-	if book != nil {
-		response := &bookpb.Book{
-			Title:    book.title,
-			Subject:  book.subject,
-			Audience: book.audience,
-			Author:   book.author,
-			Price:    book.price,
+func main() {
+	flag.Parse()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	log.Printf("Book search server is running.......")
+	store := booksearch.NewStore(booksearch.SampleBooks())
+	if *catalogFile != "" {
+		store = booksearch.NewFileStore(*catalogFile)
+		if err := store.Refresh(context.Background()); err != nil {
+			log.Printf("Book store is unavailable, serving an empty catalog: %v", err)
 		}
-		return &bookpb.GetBookResponse{
-			Book: response,
-		}, nil
 	}
-	return nil, errors.New("Book is not found for a given Title: " + req.GetTitle())
-}
-
-func (s *server) GetAllBooks(req *bookpb.GetAllBooksRequest, stream bookpb.BookSearchAPI_GetAllBooksServer) error {
-	fmt.Printf("\n Request: %v", req)
-	books := getAllTheBookByTitle(req.GetTitle())
-	if books != nil {
-		for i := 0; i < len(books); i++ {
-			book := books[i]
-			result := &bookpb.Book{
-				Title:    book.title,
-				Subject:  book.subject,
-				Audience: book.audience,
-				Author:   book.author,
-				Price:    book.price,
-			}
-			stream.Send(&bookpb.GetAllBooksResponse{
-				Book: result,
-			})
-			// Sleep for a second
-			time.Sleep(1 * time.Second)
+	bookServer := &booksearch.Server{Store: store, StreamInterval: time.Second, MaxResponseSize: *maxSendSize}
+	if *cacheSize > 0 {
+		bookServer.Cache = booksearch.NewCache(store, *cacheSize, *cacheTTL)
+	}
+	lis, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	// the gateway and the gRPC-Web handlers call this replica over loopback,
+	// accepting the largest responses it sends
+	loopback := fmt.Sprintf("localhost:%d", lis.Addr().(*net.TCPAddr).Port)
+	loopbackOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(*maxSendSize)),
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: "book-search-service",
+		Exporter:    *traceExporter,
+		Endpoint:    *traceEndpoint,
+		Insecure:    *traceInsecure,
+		File:        *traceFile,
+		SampleRatio: *traceSample,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	opts := []grpc.ServerOption{
+		tracing.ServerOption(),
+		grpc.MaxRecvMsgSize(*maxRecvSize),
+		grpc.MaxSendMsgSize(*maxSendSize),
+	}
+	keepaliveCfg := keepalive.Default
+	if *keepaliveFile != "" {
+		cfg, err := keepalive.LoadConfig(*keepaliveFile)
+		if err != nil {
+			log.Fatalf("Failed loading keepalive config: %v", err)
 		}
+		keepaliveCfg = *cfg
 	}
-	return nil
-}
-
-func (s *server) GetBooksForGivenTitles(stream bookpb.BookSearchAPI_GetBooksForGivenTitlesServer) error {
-	fmt.Println("GetBooksForGivenTitles")
-	index := 0
-	books := make([]*bookpb.Book, 100)
-	for {
-		req, err := stream.Recv()
-		if err == io.EOF {
-			finalBooks := make([]*bookpb.Book, index)
-			copy(finalBooks, books)
-			// we have finished reading the client stream
-			return stream.SendAndClose(&bookpb.GetBooksForGivenTitlesResponse{
-				Book: finalBooks,
-			})
+	opts = append(opts, keepaliveCfg.Server.ServerOptions()...)
+	logOpts := logging.Options{SampleRate: *logSample, LogPayloads: *logPayloads}
+	if *logRedact != "" {
+		logOpts.Redact = strings.Split(*logRedact, ",")
+	}
+	serverMetrics := metrics.NewServerMetrics()
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		serverMetrics.UnaryServerInterceptor(),
+		logging.UnaryServerInterceptor(logOpts),
+	}
+	shutdown := graceful.New(*drainTimeout, *shutdownDelay)
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		serverMetrics.StreamServerInterceptor(),
+		logging.StreamServerInterceptor(logOpts),
+	}
+	if *compressors != "" {
+		names := strings.Split(*compressors, ",")
+		if err := compression.Check(names...); err != nil {
+			log.Fatalf("Invalid -compression: %v", err)
 		}
+		unaryInterceptors = append(unaryInterceptors, compression.UnaryServerInterceptor(names...))
+		streamInterceptors = append(streamInterceptors, compression.StreamServerInterceptor(names...))
+	}
+	if *recordFile != "" {
+		recorder, err := recording.Open(*recordFile, recording.Options{Methods: strings.Split(*recordMethods, ",")})
 		if err != nil {
-			log.Fatalf("Error while reading client stream: %v", err)
-		}
-		book := getBookByTitle(req.GetTitle())
-		bk := &bookpb.Book{
-			Title:    book.title,
-			Subject:  book.subject,
-			Audience: book.audience,
-			Author:   book.author,
-			Price:    book.price,
+			log.Fatalf("Failed to open the recording: %v", err)
 		}
-		books[index] = bk
-		index++
+		defer recorder.Close()
+		// before authentication, so the rejected calls are recorded too
+		unaryInterceptors = append(unaryInterceptors, recorder.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, recorder.StreamServerInterceptor())
 	}
-}
-
-func (s *server) GetEachBook(stream bookpb.BookSearchAPI_GetEachBookServer) error {
-	fmt.Println("Invoking Bi Directional GetBooks method with a streaming request")
-	for {
-		req, err := stream.Recv()
-		if err == io.EOF {
-			return nil
+	var authenticator *auth.Authenticator
+	if *jwksFile != "" || *apiKeysFile != "" {
+		authenticator, err = auth.LoadAuthenticator(*jwksFile, *apiKeysFile)
+		if err != nil {
+			log.Fatalf("Failed loading credentials: %v", err)
 		}
-
+		authenticator.Exempt(healthcheck.ServicePrefix)
+		unaryInterceptors = append(unaryInterceptors, auth.UnaryServerInterceptor(authenticator))
+		streamInterceptors = append(streamInterceptors, auth.StreamServerInterceptor(authenticator))
+	}
+	if *rbacPolicy != "" {
+		policy, err := rbac.LoadPolicy(*rbacPolicy)
 		if err != nil {
-			log.Fatalf("Error while reading client stream: %v", err)
-			return err
+			log.Fatalf("Failed loading RBAC policy: %v", err)
 		}
-		book := getBookByTitle(req.GetTitle())
-		if book != nil {
-			response := &bookpb.Book{
-				Title:    book.title,
-				Subject:  book.subject,
-				Audience: book.audience,
-				Author:   book.author,
-				Price:    book.price,
-			}
-			sendError := stream.Send(&bookpb.GetEachBookResponse{
-				Book: response,
-			})
-			if sendError != nil {
-				log.Fatalf("Error while sending data to the client: %v", sendError)
-				return sendError
-			}
+		unaryInterceptors = append(unaryInterceptors, rbac.UnaryServerInterceptor(policy))
+		streamInterceptors = append(streamInterceptors, rbac.StreamServerInterceptor(policy))
+	}
+	adminServer := &admin.Server{}
+	if *rateLimits != "" {
+		cfg, err := ratelimit.LoadConfig(*rateLimits)
+		if err != nil {
+			log.Fatalf("Failed loading rate limits: %v", err)
 		}
+		adminServer.Limiter = ratelimit.New(cfg)
+		unaryInterceptors = append(unaryInterceptors, ratelimit.UnaryServerInterceptor(adminServer.Limiter))
+		streamInterceptors = append(streamInterceptors, ratelimit.StreamServerInterceptor(adminServer.Limiter))
 	}
-}
-
-func main() {
-	log.Printf("Book search server is running.......")
-	lis, err := net.Listen("tcp", port)
-	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+	// after the rate limiter, so invalid requests still count against the quotas
+	unaryInterceptors = append(unaryInterceptors, validation.UnaryServerInterceptor(bookerrors.Domain))
+	streamInterceptors = append(streamInterceptors, validation.StreamServerInterceptor(bookerrors.Domain))
+	// after validation, so the faults reach only valid calls; disabled unless
+	// -chaos or SetChaos enables it
+	adminServer.Chaos = chaos.New()
+	adminServer.Chaos.Exempt(healthcheck.ServicePrefix)
+	if *chaosFile != "" {
+		cfg, err := chaos.LoadConfig(*chaosFile)
+		if err != nil {
+			log.Fatalf("Failed loading chaos config: %v", err)
+		}
+		adminServer.Chaos.SetConfig(*cfg)
 	}
-	s := grpc.NewServer()
-	bookpb.RegisterBookSearchAPIServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve: %v", err)
+	unaryInterceptors = append(unaryInterceptors, adminServer.Chaos.UnaryServerInterceptor())
+	streamInterceptors = append(streamInterceptors, adminServer.Chaos.StreamServerInterceptor())
+	// innermost, so the other interceptors see the calls aborted by the shutdown
+	unaryInterceptors = append(unaryInterceptors, shutdown.UnaryServerInterceptor())
+	streamInterceptors = append(streamInterceptors, shutdown.StreamServerInterceptor())
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+	if *metricsAddr != "" {
+		reg := metrics.NewRegistry(serverMetrics)
+		reg.MustRegister(bookServer.Collectors()...)
+		metrics.Serve(*metricsAddr, reg)
 	}
-}
-
-type book struct {
-	title    string
-	subject  string
-	audience string
-	author   string
-	price    float32
-}
-
-// Return the list of books
-func getBooks() []book {
-	books := make([]book, 4)
-	books[0] = book{
-		title:    "Domain Driven Design",
-		subject:  "Tackling complexity in the heart of Software",
-		audience: "Software Engineers",
-		author:   "Eric Evans",
-		price:    999.0,
-	}
-	books[1] = book{
-		title:    "Java",
-		subject:  "Comprehensive guide to the entire Java laguage",
-		audience: "Software Engineers",
-		author:   "Herbert Schildt",
-		price:    999.0,
-	}
-	books[2] = book{
-		title:    "Java",
-		subject:  "Head First Java",
-		audience: "Software Engineers",
-		author:   "Kathy Sierra",
-		price:    999.0,
-	}
-	books[3] = book{
-		title:    "Java",
-		subject:  "Effective Java",
-		audience: "Software Engineers",
-		author:   "Joshua Bloch",
-		price:    999.0,
-	}
-	return books
-}
-
-// Find the book by title and return it
-func getBookByTitle(title string) *book {
-	books := getBooks()
-	for _, book := range books {
-		if book.title == title {
-			return &book
+	if *adminAddr != "" {
+		// the connections and calls of this server, reported by DiagnosticsAPI
+		tracker := admin.NewTracker()
+		opts = append(opts, grpc.StatsHandler(tracker))
+		adminLis, err := net.Listen("tcp", *adminAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen on the admin port: %v", err)
+		}
+		adminGRPC, adminHTTP, err := admin.ServePort(adminLis, admin.PortOptions{
+			Admin:         adminServer,
+			Diagnostics:   admin.NewDiagnostics(tracker),
+			Authenticator: authenticator,
+			Pprof:         *pprofEnabled,
+		})
+		if err != nil {
+			log.Fatalf("Failed to serve the admin port: %v", err)
 		}
+		shutdown.OnDrain(func() {
+			go adminGRPC.GracefulStop()
+			if adminHTTP != nil {
+				go adminHTTP.Shutdown(context.Background())
+			}
+		})
 	}
-	return nil
-}
-
-// Find the book by title and return it
-func getAllTheBookByTitle(title string) []book {
-	books := getBooks()
-	tempBooks := make([]book, len(books))
-	index := 0
-	for _, book := range books {
-		if book.title == title {
-			tempBooks[index] = book
-			index++
+	s := grpc.NewServer(opts...)
+	bookpb.RegisterBookSearchAPIServer(s, bookServer)
+	// lets grpcurl and similar tools discover the services; callers still
+	// need credentials
+	reflection.Register(s)
+	checker := healthcheck.New(
+		[]string{serviceName},
+		*healthInterval,
+		healthcheck.Probe{
+			Name:             "book store",
+			Services:         []string{serviceName},
+			Check:            store.Refresh,
+			FailureThreshold: *healthFailures,
+		},
+	)
+	checker.Register(s)
+	go checker.Run(context.Background())
+	shutdown.OnShutdown(checker.Shutdown)
+	shutdown.OnDrain(checker.CloseWatches)
+	if *httpAddr != "" {
+		gw, err := gateway.NewHandler(context.Background(), loopback, loopbackOpts,
+			bookpb.OpenAPI, bookpb.RegisterBookSearchAPIHandlerFromEndpoint)
+		if err != nil {
+			log.Fatalf("Failed to start the REST gateway: %v", err)
 		}
+		httpServer := gateway.Serve(*httpAddr, gw)
+		// refuse new HTTP requests, the ones in flight drain with the gRPC calls
+		shutdown.OnDrain(func() { go httpServer.Shutdown(context.Background()) })
 	}
-	matchedBooks := make([]book, index)
-	copy(matchedBooks, tempBooks)
-	return matchedBooks
-}
-
-// Find the book by Author
-func getBookByAuthor(author string) *book {
-	books := getBooks()
-	for _, book := range books {
-		if book.author == author {
-			return &book
+	grpcLis := lis
+	if *webEnabled {
+		conn, err := grpc.NewClient(loopback, loopbackOpts...)
+		if err != nil {
+			log.Fatalf("Failed to connect the gRPC-Web/Connect handlers: %v", err)
 		}
+		c := bookpb.NewBookSearchAPIClient(conn)
+		var origins []string
+		if *corsOrigins != "" {
+			origins = strings.Split(*corsOrigins, ",")
+		}
+		var webLis net.Listener
+		grpcLis, webLis = web.Split(lis)
+		webServer := web.Serve(webLis, web.NewHandler(origins,
+			web.Unary("/"+serviceName+"/GetBook", c.GetBook),
+			web.ServerStream[bookpb.GetAllBooksRequest, bookpb.GetAllBooksResponse]("/"+serviceName+"/GetAllBooks", c.GetAllBooks),
+			web.ClientStream[bookpb.GetBooksForGivenTitlesRequest, bookpb.GetBooksForGivenTitlesResponse]("/"+serviceName+"/GetBooksForGivenTitles", c.GetBooksForGivenTitles),
+			web.BidiStream[bookpb.GetEachBookRequest, bookpb.GetEachBookResponse]("/"+serviceName+"/GetEachBook", c.GetEachBook),
+		))
+		shutdown.OnDrain(func() { go webServer.Shutdown(context.Background()) })
+	}
+	if err := shutdown.Serve(s, grpcLis); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
 	}
-	return nil
 }
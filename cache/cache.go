@@ -0,0 +1,148 @@
+// Package cache holds the size-bounded LRU cache with expiring entries used
+// by the book search server in front of its catalog and by the clients in
+// front of the server, and the cache-control header through which the server
+// tells the clients how long they may keep a response.
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// ControlKey is the response header carrying the cache hint of the server,
+// e.g. "max-age=30" or "no-store", as in HTTP
+const ControlKey = "cache-control"
+
+// Stats counts the lookups of a cache
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	// Evictions counts the entries dropped to make room, not the expired ones
+	Evictions uint64
+	Entries   int
+}
+
+// LRU is a cache of at most a fixed number of entries, each valid until its
+// expiry. When full, adding an entry evicts the least recently used one. It
+// is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	entries map[K]*list.Element
+	// order holds the entries, the most recently used first
+	order *list.List
+	stats Stats
+	now   func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// New returns a cache of at most size entries
+func New[K comparable, V any](size int) *LRU[K, V] {
+	return &LRU[K, V]{size: size, entries: map[K]*list.Element{}, order: list.New(), now: time.Now}
+}
+
+// Get returns the value of key and how long it remains valid. Expired
+// entries are removed and reported as misses.
+func (c *LRU[K, V]) Get(key K) (value V, ttl time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, found := c.entries[key]
+	if found {
+		e := el.Value.(*entry[K, V])
+		if ttl = e.expires.Sub(c.now()); ttl > 0 {
+			c.order.MoveToFront(el)
+			c.stats.Hits++
+			return e.value, ttl, true
+		}
+		c.remove(el)
+	}
+	c.stats.Misses++
+	return value, 0, false
+}
+
+// Add stores value under key for ttl, replacing any previous value. Nothing
+// is stored when ttl is not positive.
+func (c *LRU[K, V]) Add(key K, value V, ttl time.Duration) {
+	if ttl <= 0 || c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &entry[K, V]{key: key, value: value, expires: c.now().Add(ttl)}
+	if el, found := c.entries[key]; found {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Purge removes every entry
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.order.Init()
+}
+
+// Stats returns the counters of the cache
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.order.Len()
+	return s
+}
+
+func (c *LRU[K, V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry[K, V]).key)
+}
+
+// MaxAge returns the cache-control header allowing clients to keep a
+// response for ttl, rounded down to the second
+func MaxAge(ttl time.Duration) metadata.MD {
+	if s := int64(ttl / time.Second); s > 0 {
+		return metadata.Pairs(ControlKey, fmt.Sprintf("max-age=%d", s))
+	}
+	return metadata.Pairs(ControlKey, "no-store")
+}
+
+// ParseMaxAge returns how long the cache-control header of md allows a
+// response to be kept, 0 when it is missing or forbids caching
+func ParseMaxAge(md metadata.MD) time.Duration {
+	var maxAge time.Duration
+	for _, v := range md.Get(ControlKey) {
+		for _, directive := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+			switch name {
+			case "no-store", "no-cache":
+				return 0
+			case "max-age":
+				s, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || s < 0 {
+					return 0
+				}
+				// no longer than a year, which also keeps the duration from
+				// overflowing
+				maxAge = time.Duration(min(s, 365*24*60*60)) * time.Second
+			}
+		}
+	}
+	return maxAge
+}
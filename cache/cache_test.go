@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := New[string, int](2)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1, time.Minute)
+	c.Add("b", 2, time.Second)
+	if v, ttl, ok := c.Get("a"); !ok || v != 1 || ttl != time.Minute {
+		t.Errorf("Get(a) = %v, %v, %v, want 1, 1m, true", v, ttl, ok)
+	}
+	// b is now the least recently used
+	c.Add("c", 3, time.Minute)
+	if _, _, ok := c.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, _, ok := c.Get("a"); ok {
+		t.Error("a did not expire")
+	}
+	c.Add("d", 4, 0)
+	if _, _, ok := c.Get("d"); ok {
+		t.Error("entry without a TTL was stored")
+	}
+
+	want := Stats{Hits: 1, Misses: 3, Evictions: 1, Entries: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	c.Purge()
+	if got := c.Stats().Entries; got != 0 {
+		t.Errorf("%d entries after Purge", got)
+	}
+}
+
+func TestParseMaxAge(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   time.Duration
+	}{
+		{header: nil, want: 0},
+		{header: []string{"max-age=30"}, want: 30 * time.Second},
+		{header: []string{"public, Max-Age=5"}, want: 5 * time.Second},
+		{header: []string{"max-age=30, no-store"}, want: 0},
+		{header: []string{"no-cache"}, want: 0},
+		{header: []string{"max-age=-1"}, want: 0},
+		{header: []string{"max-age=soon"}, want: 0},
+		{header: []string{"max-age=99999999999999"}, want: 365 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		md := metadata.MD{}
+		if tt.header != nil {
+			md.Set(ControlKey, tt.header...)
+		}
+		if got := ParseMaxAge(md); got != tt.want {
+			t.Errorf("ParseMaxAge(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+	if got := ParseMaxAge(MaxAge(90 * time.Second)); got != 90*time.Second {
+		t.Errorf("ParseMaxAge(MaxAge(90s)) = %v", got)
+	}
+	if got := ParseMaxAge(MaxAge(time.Millisecond)); got != 0 {
+		t.Errorf("ParseMaxAge(MaxAge(1ms)) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/vpulimamidi/grpc-go-course/admin/adminpb"
+	"google.golang.org/grpc"
+)
+
+// QuotaUsage runs the quota command against the admin port on cc: it prints
+// the daily quota counters, optionally filtered by -client and -method
+func (c *ConnFlags) QuotaUsage(cc grpc.ClientConnInterface, args []string) error {
+	fs := flag.NewFlagSet("quota", flag.ExitOnError)
+	client := fs.String("client", "", "Only show this client, e.g. subject:ops or peer:127.0.0.1")
+	method := fs.String("method", "", "Only show this fully qualified method")
+	fs.Parse(args)
+	ctx, cancel := c.Context()
+	defer cancel()
+	res, err := adminpb.NewAdminAPIClient(cc).GetQuotaUsage(ctx, &adminpb.GetQuotaUsageRequest{Client: *client, Method: *method})
+	if err != nil {
+		return err
+	}
+	return c.Print(res)
+}
+
+// Chaos runs the chaos command against the admin port on cc: it shows the
+// faults injected by the server, replaces them with the -d config, or turns
+// the injection on or off keeping the rules
+func (c *ConnFlags) Chaos(cc grpc.ClientConnInterface, args []string) error {
+	fs := flag.NewFlagSet("chaos", flag.ExitOnError)
+	data := fs.String("d", "", `ChaosConfig as JSON, e.g. '{"enabled":true,"rules":[{"percentage":10,"code":"UNAVAILABLE"}]}'`)
+	fs.Parse(args)
+	ctx, cancel := c.Context()
+	defer cancel()
+	client := adminpb.NewAdminAPIClient(cc)
+	cfg := &adminpb.ChaosConfig{}
+	switch {
+	case *data != "":
+		if err := Unmarshal(*data, cfg); err != nil {
+			return err
+		}
+	case fs.Arg(0) == "on" || fs.Arg(0) == "off":
+		var err error
+		if cfg, err = client.GetChaos(ctx, &adminpb.GetChaosRequest{}); err != nil {
+			return err
+		}
+		cfg.Enabled = fs.Arg(0) == "on"
+	case fs.NArg() == 0:
+		res, err := client.GetChaos(ctx, &adminpb.GetChaosRequest{})
+		if err != nil {
+			return err
+		}
+		return c.Print(res)
+	default:
+		return fmt.Errorf("chaos: unexpected argument %q, expected on or off", fs.Arg(0))
+	}
+	res, err := client.SetChaos(ctx, &adminpb.SetChaosRequest{Config: cfg})
+	if err != nil {
+		return err
+	}
+	return c.Print(res)
+}
+
+// Diagnostics runs the diag command against the admin port on cc: it prints
+// the connections, method counters, flags, build and runtime of the server
+func (c *ConnFlags) Diagnostics(cc grpc.ClientConnInterface) error {
+	ctx, cancel := c.Context()
+	defer cancel()
+	res, err := adminpb.NewDiagnosticsAPIClient(cc).GetDiagnostics(ctx, &adminpb.GetDiagnosticsRequest{})
+	if err != nil {
+		return err
+	}
+	return c.Print(res)
+}
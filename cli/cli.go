@@ -0,0 +1,145 @@
+// Package cli holds the pieces shared by the booksctl and calcctl command
+// line clients: connection flags, JSON input, JSON output and the commands
+// of the admin port.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/keepalive"
+	"github.com/vpulimamidi/grpc-go-course/rpcclient"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ConnFlags are the connection settings common to every command
+type ConnFlags struct {
+	Target     string
+	TLS        bool
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	Token      string
+	APIKey     string
+	Timeout    time.Duration
+	Balancer   string
+	Compressor string
+	MaxRecv    int
+	Keepalive  time.Duration
+	Pretty     bool
+}
+
+// Register adds the connection flags to fs, using target as the default
+// server address
+func (c *ConnFlags) Register(fs *flag.FlagSet, target string) {
+	fs.StringVar(&c.Target, "target", target, "Server address, or several with dns:///host:port, static:///host1:port,host2:port or file:///path/endpoints.yaml")
+	fs.BoolVar(&c.TLS, "tls", false, "Connect with TLS")
+	fs.StringVar(&c.CAFile, "ca", "", "CA certificate used to verify the server (implies -tls)")
+	fs.StringVar(&c.CertFile, "cert", "", "Client certificate for mutual TLS (implies -tls)")
+	fs.StringVar(&c.KeyFile, "key", "", "Client private key for mutual TLS")
+	fs.StringVar(&c.ServerName, "server-name", "", "Override the server name checked against the certificate")
+	fs.StringVar(&c.Token, "token", "", "Bearer JWT sent with every call")
+	fs.StringVar(&c.APIKey, "api-key", "", "API key sent with every call")
+	fs.DurationVar(&c.Timeout, "timeout", 30*time.Second, "Deadline of each call, 0 for none")
+	fs.StringVar(&c.Balancer, "lb", "", "Spread the calls over the addresses of -target: round_robin or least_request")
+	fs.StringVar(&c.Compressor, "compression", "", "Compress the requests with gzip or zstd, uncompressed when empty")
+	fs.IntVar(&c.MaxRecv, "max-recv-size", 0, "Largest message received, in bytes; 0 for the 4 MiB default")
+	fs.DurationVar(&c.Keepalive, "keepalive-time", keepalive.Default.Client.Time, "Idle time after which the connection is pinged, 0 to never ping; the servers reject pings more frequent than 10s by default")
+	fs.BoolVar(&c.Pretty, "pretty", false, "Indent the JSON output")
+}
+
+// Options returns the connection options set by the flags. Calls are
+// bounded by -timeout through Context, so the client timeout is left off.
+func (c *ConnFlags) Options() rpcclient.Options {
+	ka := keepalive.Default.Client
+	ka.Time = c.Keepalive
+	return rpcclient.Options{
+		TLS:              c.TLS,
+		CAFile:           c.CAFile,
+		CertFile:         c.CertFile,
+		KeyFile:          c.KeyFile,
+		ServerName:       c.ServerName,
+		Token:            c.Token,
+		APIKey:           c.APIKey,
+		Timeout:          -1,
+		Balancer:         c.Balancer,
+		Compressor:       c.Compressor,
+		MaxRecvMsgSize:   c.MaxRecv,
+		DisableKeepalive: c.Keepalive <= 0,
+		Keepalive:        ka,
+	}
+}
+
+// Context returns the context of a call, bounded by -timeout
+func (c *ConnFlags) Context() (context.Context, context.CancelFunc) {
+	if c.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), c.Timeout)
+}
+
+// Print writes msg to stdout as JSON, one message per line unless -pretty
+// is set
+func (c *ConnFlags) Print(msg proto.Message) error {
+	opts := protojson.MarshalOptions{EmitUnpopulated: true}
+	if c.Pretty {
+		opts.Multiline = true
+		opts.Indent = "  "
+	}
+	data, err := opts.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
+
+// Unmarshal parses a JSON request into msg
+func Unmarshal(data string, msg proto.Message) error {
+	if err := protojson.Unmarshal([]byte(data), msg); err != nil {
+		return fmt.Errorf("invalid %s: %w", msg.ProtoReflect().Descriptor().Name(), err)
+	}
+	return nil
+}
+
+// Decoder reads a stream of JSON messages, one after the other, such as
+// newline delimited JSON from stdin
+type Decoder struct {
+	dec *json.Decoder
+}
+
+// NewDecoder returns a Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(bufio.NewReader(r))}
+}
+
+// Next decodes the next message into msg. It returns io.EOF at the end of
+// the input.
+func (d *Decoder) Next(msg proto.Message) error {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return err
+	}
+	return Unmarshal(string(raw), msg)
+}
+
+// Fatal prints err, with the gRPC status code and details when available,
+// and exits with a non-zero status
+func Fatal(err error) {
+	if _, ok := status.FromError(err); ok {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", apierror.Describe(err))
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
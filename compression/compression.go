@@ -0,0 +1,156 @@
+// Package compression registers the message compressors the servers and
+// clients negotiate per call, gzip and zstd, and the server interceptors
+// choosing the one the responses are sent with.
+//
+// A client compresses its requests with the compressor of
+// rpcclient.Options.Compressor and lists every registered compressor in the
+// grpc-accept-encoding header. The server decompresses what it receives and,
+// with the interceptors, compresses its responses with its preferred
+// compressor among the ones the client accepts; without them it answers with
+// the compressor of the request.
+package compression
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	// Gzip is the name of the gzip compressor, registered by grpc-go
+	Gzip = gzip.Name
+	// Zstd is the name of the zstd compressor
+	Zstd = "zstd"
+)
+
+// Names lists the registered compressors, the one compressing better first
+var Names = []string{Zstd, Gzip}
+
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// Check returns an error unless every name is a registered compressor
+func Check(names ...string) error {
+	for _, name := range names {
+		if encoding.GetCompressor(name) == nil {
+			return fmt.Errorf("unknown compressor %q, want one of %v", name, Names)
+		}
+	}
+	return nil
+}
+
+// zstdCompressor implements encoding.Compressor. Encoders and decoders are
+// expensive to create, so they are pooled.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func (c *zstdCompressor) Name() string {
+	return Zstd
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	enc, ok := c.encoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+		// messages are small, so one goroutine compresses faster than several
+		enc, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		if err != nil {
+			return nil, err
+		}
+	}
+	enc.Reset(w)
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, ok := c.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+		dec, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := dec.Reset(r); err != nil {
+		c.decoders.Put(dec)
+		return nil, err
+	}
+	return &zstdReader{Decoder: dec, pool: &c.decoders}, nil
+}
+
+// zstdWriter returns its encoder to the pool once closed
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// zstdReader returns its decoder to the pool once the message is read
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.Decoder == nil {
+		return 0, io.EOF
+	}
+	n, err := r.Decoder.Read(p)
+	if err == io.EOF {
+		r.pool.Put(r.Decoder)
+		r.Decoder = nil
+	}
+	return n, err
+}
+
+// choose returns the first of preferred the client of ctx accepts, "" if
+// none
+func choose(ctx context.Context, preferred []string) string {
+	accepted, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		return ""
+	}
+	for _, name := range preferred {
+		if slices.Contains(accepted, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// UnaryServerInterceptor compresses the responses with the first of
+// preferred the client accepts, or like the request when it accepts none
+func UnaryServerInterceptor(preferred ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if name := choose(ctx, preferred); name != "" {
+			grpc.SetSendCompressor(ctx, name)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor compresses the messages sent on streams like
+// UnaryServerInterceptor
+func StreamServerInterceptor(preferred ...string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if name := choose(ss.Context(), preferred); name != "" {
+			grpc.SetSendCompressor(ss.Context(), name)
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,159 @@
+package compression_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/compression"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/experimental"
+	"google.golang.org/grpc/stats"
+)
+
+func TestZstdRoundTrip(t *testing.T) {
+	c := encoding.GetCompressor(compression.Zstd)
+	if c == nil {
+		t.Fatal("zstd is not registered")
+	}
+	msg := []byte(strings.Repeat("Domain Driven Design ", 100))
+	// twice, so pooled encoders and decoders are reused
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(msg)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() >= len(msg) {
+			t.Errorf("compressed to %d bytes from %d", buf.Len(), len(msg))
+		}
+		r, err := c.Decompress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Errorf("got %q after a round trip", got)
+		}
+	}
+}
+
+// plainCompressor is registered by the test only, so the server does not
+// prefer it
+type plainCompressor struct{}
+
+const plain = "test-plain"
+
+func (plainCompressor) Name() string { return plain }
+
+func (plainCompressor) Compress(w io.Writer) (io.WriteCloser, error) { return nopCloser{w}, nil }
+
+func (plainCompressor) Decompress(r io.Reader) (io.Reader, error) { return r, nil }
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func init() {
+	encoding.RegisterCompressor(plainCompressor{})
+}
+
+// responseEncoding records the grpc-encoding of the last response header
+// received by the client
+type responseEncoding struct {
+	mu   sync.Mutex
+	last string
+}
+
+func (e *responseEncoding) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (e *responseEncoding) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.InHeader); ok {
+		e.mu.Lock()
+		e.last = h.Compression
+		e.mu.Unlock()
+	}
+}
+
+func (e *responseEncoding) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (e *responseEncoding) HandleConn(context.Context, stats.ConnStats) {}
+
+func (e *responseEncoding) get() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.last
+}
+
+func TestNegotiation(t *testing.T) {
+	enc := &responseEncoding{}
+	h := testharness.Start(t, testharness.Options{
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{compression.UnaryServerInterceptor(compression.Names...)},
+		StreamInterceptors: []grpc.StreamServerInterceptor{compression.StreamServerInterceptor(compression.Names...)},
+		DialOptions:        []grpc.DialOption{grpc.WithStatsHandler(enc)},
+	})
+	for _, tc := range []struct {
+		name     string
+		request  string
+		accepted []string
+		want     string
+	}{
+		{"uncompressed request, every compressor accepted", "", nil, compression.Zstd},
+		{"gzip request, every compressor accepted", compression.Gzip, nil, compression.Zstd},
+		{"zstd request, every compressor accepted", compression.Zstd, nil, compression.Zstd},
+		{"gzip accepted", "", []string{compression.Gzip}, compression.Gzip},
+		{"preference of the server", compression.Gzip, []string{compression.Gzip, compression.Zstd}, compression.Zstd},
+		{"unknown to the server, answered like the request", plain, []string{plain}, plain},
+		{"unknown to the server, uncompressed request", "", []string{plain}, ""},
+	} {
+		var opts []grpc.CallOption
+		if tc.request != "" {
+			opts = append(opts, grpc.UseCompressor(tc.request))
+		}
+		if tc.accepted != nil {
+			opts = append(opts, experimental.AcceptCompressors(tc.accepted...))
+		}
+		res, err := h.Books.GetBook(context.Background(), &bookpb.GetBookRequest{Title: "Java"}, opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if res.GetBook().GetAuthor() != "Herbert Schildt" {
+			t.Errorf("%s: got %v", tc.name, res)
+		}
+		if got := enc.get(); got != tc.want {
+			t.Errorf("%s: response compressed with %q, want %q", tc.name, got, tc.want)
+		}
+
+		// streams negotiate the same way
+		stream, err := h.Books.GetAllBooks(context.Background(), &bookpb.GetAllBooksRequest{Title: "Java"}, opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := enc.get(); got != tc.want {
+			t.Errorf("%s: stream compressed with %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if err := compression.Check("gzip", "br"); err == nil {
+		t.Error("Check accepted an unknown compressor")
+	}
+}
@@ -0,0 +1,134 @@
+// calcctl drives CalculatorAPI from the command line.
+//
+// Operands can be given as arguments, or whole requests as JSON with -d.
+// Responses are printed as JSON.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/vpulimamidi/grpc-go-course/cli"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computeclient"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+const usageText = `Usage: calcctl [flags] <command> [arguments]
+
+Commands:
+  divide [-d json] <dividend> <divisor>
+                             Divide: divide two integers
+  sum [-d json] <number1> <number2>
+                             Sum: add two integers
+
+Admin commands, served on the admin port of the server, e.g. -target localhost:9992:
+  quota [-client c] [-method m]
+                             AdminAPI.GetQuotaUsage: show the daily quota counters
+  chaos [-d json] [on|off]   AdminAPI.GetChaos/SetChaos: show, replace or toggle the injected faults
+  diag                       DiagnosticsAPI.GetDiagnostics: show the connections, method counters,
+                             flags, build and runtime
+
+Flags:
+`
+
+var conn cli.ConnFlags
+
+func main() {
+	conn.Register(flag.CommandLine, computeclient.DefaultTarget)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usageText)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c, err := computeclient.New(conn.Target, conn.Options())
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer c.Close()
+
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "divide":
+		err = divide(c, args)
+	case "sum":
+		err = sum(c, args)
+	case "quota":
+		err = conn.QuotaUsage(c.Conn(), args)
+	case "chaos":
+		err = conn.Chaos(c.Conn(), args)
+	case "diag":
+		err = conn.Diagnostics(c.Conn())
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		cli.Fatal(err)
+	}
+}
+
+// parseOperandsRequest fills req from the -d flag or from two integer
+// arguments
+func parseOperandsRequest(name string, args []string, req proto.Message, setOperands func(a, b int32)) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	data := fs.String("d", "", "Request as JSON")
+	fs.Parse(args)
+	if *data != "" {
+		return cli.Unmarshal(*data, req)
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("%s needs two integers or a -d JSON request", name)
+	}
+	var operands [2]int32
+	for i := range operands {
+		n, err := strconv.ParseInt(fs.Arg(i), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid operand %q: %w", fs.Arg(i), err)
+		}
+		operands[i] = int32(n)
+	}
+	setOperands(operands[0], operands[1])
+	return nil
+}
+
+func divide(c computepb.CalculatorAPIClient, args []string) error {
+	req := &computepb.DivideRequest{}
+	err := parseOperandsRequest("divide", args, req, func(a, b int32) {
+		req.Dividend, req.Divisor = a, b
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := conn.Context()
+	defer cancel()
+	res, err := c.Divide(ctx, req)
+	if err != nil {
+		return err
+	}
+	return conn.Print(res)
+}
+
+func sum(c computepb.CalculatorAPIClient, args []string) error {
+	req := &computepb.SumRequest{}
+	err := parseOperandsRequest("sum", args, req, func(a, b int32) {
+		req.Number1, req.Number2 = a, b
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := conn.Context()
+	defer cancel()
+	res, err := c.Sum(ctx, req)
+	if err != nil {
+		return err
+	}
+	return conn.Print(res)
+}
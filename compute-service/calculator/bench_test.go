@@ -0,0 +1,42 @@
+package calculator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+)
+
+// The benchmarks call the handlers through the in-process harness, without
+// the artificial delay of Sum:
+//
+//	go test -bench . -benchmem ./compute-service/calculator
+
+func BenchmarkDivide(b *testing.B) {
+	h := testharness.Start(b, testharness.Options{})
+	req := &computepb.DivideRequest{Dividend: 10, Divisor: 4}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := h.Calculator.Divide(context.Background(), req); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkSum(b *testing.B) {
+	h := testharness.Start(b, testharness.Options{})
+	req := &computepb.SumRequest{Number1: 10, Number2: 20}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := h.Calculator.Sum(context.Background(), req); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,69 @@
+// Package calculator implements CalculatorAPI, the service served by the
+// compute server. It is a package of its own so the handlers can be served
+// in-process, e.g. by the testharness package.
+package calculator
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ServiceName is the fully qualified name of the service
+	ServiceName = "calculator.CalculatorAPI"
+	// ErrorDomain is reported in the ErrorInfo of the errors of the handlers
+	ErrorDomain apierror.Domain = "compute-service"
+	// ReasonDivisionByZero is the ErrorInfo reason of a zero divisor
+	ReasonDivisionByZero = "DIVISION_BY_ZERO"
+)
+
+var divisionsByZero = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "calculator_divisions_by_zero_total",
+	Help: "Number of Divide calls rejected because the divisor was zero.",
+})
+
+// Collectors returns the Prometheus metrics of the handlers
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{divisionsByZero}
+}
+
+// Server implements computepb.CalculatorAPIServer
+type Server struct {
+	computepb.UnimplementedCalculatorAPIServer
+
+	// SumDelay is how long Sum takes to answer, to demonstrate deadlines
+	SumDelay time.Duration
+}
+
+// Divide ..
+func (s *Server) Divide(ctx context.Context, req *computepb.DivideRequest) (*computepb.DivideResponse, error) {
+	if req.GetDivisor() == 0 {
+		divisionsByZero.Inc()
+		return nil, ErrorDomain.InvalidArgument(ReasonDivisionByZero,
+			apierror.Violation("divisor", "must not be zero"))
+	}
+	result := float64(req.GetDividend()) / float64(req.GetDivisor())
+	return &computepb.DivideResponse{
+		Result: result,
+	}, nil
+}
+
+// Sum adds the numbers after SumDelay
+func (s *Server) Sum(ctx context.Context, req *computepb.SumRequest) (*computepb.SumResponse, error) {
+	select {
+	case <-ctx.Done():
+		// the client canceled the request or its deadline expired
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case <-time.After(s.SumDelay):
+	}
+	result := req.GetNumber1() + req.GetNumber2()
+	res := &computepb.SumResponse{
+		Result: result,
+	}
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package calculator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/calculator"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/validation"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDivide(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{})
+	tests := []struct {
+		name               string
+		dividend, divisor  int32
+		want               float64
+		wantCode           codes.Code
+		wantReason         string
+		wantViolationField string
+	}{
+		{name: "exact", dividend: 20, divisor: 10, want: 2},
+		{name: "fraction", dividend: 7, divisor: 2, want: 3.5},
+		{name: "negative", dividend: -9, divisor: 3, want: -3},
+		{name: "zero dividend", dividend: 0, divisor: 5, want: 0},
+		{name: "division by zero", dividend: 1, divisor: 0, wantCode: codes.InvalidArgument, wantReason: calculator.ReasonDivisionByZero, wantViolationField: "divisor"},
+		{name: "dividend out of range", dividend: 2000000000, divisor: 1, wantCode: codes.InvalidArgument, wantReason: validation.ReasonInvalidRequest, wantViolationField: "dividend"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := h.Calculator.Divide(context.Background(), &computepb.DivideRequest{Dividend: tt.dividend, Divisor: tt.divisor})
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("got code %v (%v), want %v", got, err, tt.wantCode)
+			}
+			if err != nil {
+				if got := apierror.Reason(err); got != tt.wantReason {
+					t.Errorf("got reason %q, want %q", got, tt.wantReason)
+				}
+				if got := violationField(err); got != tt.wantViolationField {
+					t.Errorf("got violation of %q, want %q", got, tt.wantViolationField)
+				}
+				return
+			}
+			if got := res.GetResult(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// violationField returns the field of the first BadRequest violation of err
+func violationField(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok && len(br.GetFieldViolations()) > 0 {
+			return br.GetFieldViolations()[0].GetField()
+		}
+	}
+	return ""
+}
+
+func TestSum(t *testing.T) {
+	h := testharness.Start(t, testharness.Options{SumDelay: 100 * time.Millisecond})
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		timeout  time.Duration
+		want     int32
+		wantCode codes.Code
+	}{
+		{name: "no deadline", ctx: context.Background(), want: 30},
+		{name: "deadline long enough", ctx: context.Background(), timeout: 5 * time.Second, want: 30},
+		{name: "deadline too short", ctx: context.Background(), timeout: 10 * time.Millisecond, wantCode: codes.DeadlineExceeded},
+		{name: "canceled", ctx: canceled, wantCode: codes.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			res, err := h.Calculator.Sum(ctx, &computepb.SumRequest{Number1: 10, Number2: 20})
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("got code %v (%v), want %v", got, err, tt.wantCode)
+			}
+			if got := res.GetResult(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package calculator_test
+
+import (
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/compute-service/calculator"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// The fuzz targets send arbitrary bytes as the requests of the handlers,
+// like those of the booksearch package. Division by zero is reported as
+// InvalidArgument, so no other error code is expected. To fuzz a target:
+//
+//	go test -run XXX -fuzz FuzzDivide -fuzztime 1m -fuzzminimizetime 100x ./compute-service/calculator
+
+func FuzzDivide(f *testing.F) {
+	f.Add(marshal(&computepb.DivideRequest{Dividend: 10, Divisor: 4}))
+	f.Add(marshal(&computepb.DivideRequest{Dividend: 10}))
+	h := testharness.Start(f, testharness.Options{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		testharness.FuzzCall(t, h.ComputeConn, "/"+calculator.ServiceName+"/Divide", &grpc.StreamDesc{}, [][]byte{data},
+			&computepb.DivideRequest{}, &computepb.DivideResponse{})
+	})
+}
+
+func FuzzSum(f *testing.F) {
+	f.Add(marshal(&computepb.SumRequest{Number1: 10, Number2: 20}))
+	f.Add(marshal(&computepb.SumRequest{Number1: 1000000000, Number2: 1000000000}))
+	h := testharness.Start(f, testharness.Options{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		testharness.FuzzCall(t, h.ComputeConn, "/"+calculator.ServiceName+"/Sum", &grpc.StreamDesc{}, [][]byte{data},
+			&computepb.SumRequest{}, &computepb.SumResponse{})
+	})
+}
+
+func marshal(m proto.Message) []byte {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
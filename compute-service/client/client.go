@@ -2,43 +2,75 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computeclient"
 	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/metrics"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 )
 
-const (
-	targetHost = "localhost:9988"
+var (
+	target        = flag.String("target", computeclient.DefaultTarget, "Server address, or several with dns:///, static:/// or file:/// targets")
+	balancer      = flag.String("lb", "", "Spread the calls over the addresses of -target: round_robin or least_request")
+	token         = flag.String("token", "", "Bearer JWT sent with every call")
+	apiKey        = flag.String("api-key", "", "API key sent with every call")
+	metricsAddr   = flag.String("metrics-addr", "", "Address of an HTTP listener serving the client Prometheus /metrics while the examples run")
+	traceExporter = flag.String("trace-exporter", "", "OpenTelemetry span exporter: otlp, stdout or file, empty to disable tracing")
+	traceEndpoint = flag.String("trace-endpoint", "localhost:4317", "OTLP gRPC collector address used by the otlp exporter")
+	traceInsecure = flag.Bool("trace-insecure", true, "Connect to the OTLP collector without TLS")
+	traceFile     = flag.String("trace-file", "traces.json", "File receiving the spans with the file exporter")
+	traceSample   = flag.Float64("trace-sample", 1, "Fraction of new traces that are recorded")
 )
 
 func main() {
+	flag.Parse()
 	fmt.Printf("Client for Compute service\n")
 	tlsEnabled := false
-	opts := grpc.WithInsecure()
+	opts := computeclient.Options{
+		Token:    *token,
+		APIKey:   *apiKey,
+		Balancer: *balancer,
+	}
 	if tlsEnabled {
 		// Certificate Authority Trust certificate
-		certFile := "../../ssl/ca.crt"
-		creds, sslErr := credentials.NewClientTLSFromFile(certFile, "")
-		if sslErr != nil {
-			log.Fatalf("Error while loading CA trust certificate: %v", sslErr)
-			return
-		}
-		opts = grpc.WithTransportCredentials(creds)
+		opts.CAFile = "../../ssl/ca.crt"
 	}
-	// Create a client connection using target host
-	clientConnection, err := grpc.Dial(targetHost, opts)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: "compute-client",
+		Exporter:    *traceExporter,
+		Endpoint:    *traceEndpoint,
+		Insecure:    *traceInsecure,
+		File:        *traceFile,
+		SampleRatio: *traceSample,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	opts.DialOptions = []grpc.DialOption{tracing.DialOption()}
+	if *metricsAddr != "" {
+		clientMetrics := metrics.NewClientMetrics()
+		metrics.Serve(*metricsAddr, metrics.NewRegistry(clientMetrics))
+		opts.DialOptions = append(opts.DialOptions,
+			grpc.WithChainUnaryInterceptor(clientMetrics.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(clientMetrics.StreamClientInterceptor()),
+		)
+	}
+	// Create a calculator client, retrying the calls while the server is unavailable
+	calculatorClient, err := computeclient.New(*target, opts)
 	if err != nil {
 		log.Fatalf("Could not connect to server %v", err)
 	}
 	// This code will make sure to close the connection at the end of this main function
-	defer clientConnection.Close()
-	calculatorClient := computepb.NewCalculatorAPIClient(clientConnection)
+	defer calculatorClient.Close()
 	req1 := &computepb.DivideRequest{
 		Dividend: 20,
 		Divisor:  10,
@@ -66,10 +98,11 @@ func doDevide(calculatorClient computepb.CalculatorAPIClient, req *computepb.Div
 		if ok {
 			// actual error from gRPC (user error)
 			fmt.Println("Response Code: ", respErr.Code())
-			if respErr.Code() == codes.InvalidArgument {
+			fmt.Println("Error: ", apierror.Describe(err))
+			if apierror.Reason(err) == "DIVISION_BY_ZERO" {
 				fmt.Println("Invalid divisor is sent!")
-				return
 			}
+			return
 		} else {
 			log.Fatalf("Error calling Divide method: %v", err)
 			return
@@ -97,7 +130,7 @@ func doSum(c computepb.CalculatorAPIClient, timeout time.Duration) {
 				fmt.Println("Error Message: ", statusErr.Message())
 				fmt.Println("Timeout was hit! Deadline was exceeded")
 			} else {
-				fmt.Printf("unexpected error: %v", statusErr)
+				fmt.Printf("unexpected error: %s", apierror.Describe(statusErr.Err()))
 			}
 		} else {
 			log.Fatalf("error while calling Sum RPC: %v", err)
@@ -0,0 +1,75 @@
+// Package computeclient is the Go client of the compute service. It wraps the
+// generated CalculatorAPIClient with the connection defaults of rpcclient
+// (retries on Unavailable, hedged Divide calls, per-call timeouts) and adds
+// typed helpers.
+package computeclient
+
+import (
+	"context"
+
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/rpcclient"
+	"google.golang.org/grpc"
+)
+
+// DefaultTarget is the address the compute server listens on
+const DefaultTarget = "localhost:9988"
+
+// Service is the compute service. Both methods are idempotent, but Sum is
+// deliberately slow, so hedging it would only multiply the work: only Divide
+// is hedged.
+var Service = rpcclient.Service{
+	Name:       "calculator.CalculatorAPI",
+	Idempotent: []string{"Divide"},
+}
+
+// Options configure the connection, see rpcclient.Options
+type Options = rpcclient.Options
+
+// Client calls the compute service. The generated methods are available as
+// is, next to the helpers.
+type Client struct {
+	computepb.CalculatorAPIClient
+	conn *grpc.ClientConn
+}
+
+// New returns a client of the server at target. The server uses TLS with the
+// certificates of ../../ssl when its tlsEnabled switch is on: set Options.CAFile
+// to ssl/ca.crt then.
+func New(target string, o Options) (*Client, error) {
+	conn, err := rpcclient.Dial(target, Service, o)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{CalculatorAPIClient: computepb.NewCalculatorAPIClient(conn), conn: conn}, nil
+}
+
+// Conn returns the connection of the client, e.g. to call the AdminAPI of the
+// same server
+func (c *Client) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Quotient returns dividend divided by divisor. The call fails with
+// InvalidArgument when divisor is zero.
+func (c *Client) Quotient(ctx context.Context, dividend, divisor int32) (float64, error) {
+	res, err := c.Divide(ctx, &computepb.DivideRequest{Dividend: dividend, Divisor: divisor})
+	if err != nil {
+		return 0, err
+	}
+	return res.GetResult(), nil
+}
+
+// Total returns number1 + number2
+func (c *Client) Total(ctx context.Context, number1, number2 int32) (int32, error) {
+	res, err := c.Sum(ctx, &computepb.SumRequest{Number1: number1, Number2: number2})
+	if err != nil {
+		return 0, err
+	}
+	return res.GetResult(), nil
+}
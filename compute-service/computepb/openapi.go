@@ -0,0 +1,8 @@
+package computepb
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI document of the REST gateway, generated from compute.proto
+//
+//go:embed compute.swagger.json
+var OpenAPI []byte
@@ -20,67 +20,115 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"flag"
 	"log"
+	"log/slog"
 	"net"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/vpulimamidi/grpc-go-course/admin"
+	"github.com/vpulimamidi/grpc-go-course/compression"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/calculator"
 	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/gateway"
+	"github.com/vpulimamidi/grpc-go-course/graceful"
+	"github.com/vpulimamidi/grpc-go-course/healthcheck"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/chaos"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/logging"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/metrics"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/ratelimit"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/rbac"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/recording"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/tracing"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/validation"
+	"github.com/vpulimamidi/grpc-go-course/keepalive"
+	"github.com/vpulimamidi/grpc-go-course/web"
 	"google.golang.org/grpc/credentials"
-
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
 
 	"google.golang.org/grpc"
 )
 
 const (
 	port = ":9988"
+	// serviceName is the fully qualified name reported by the health service
+	serviceName = calculator.ServiceName
 )
 
-type server struct {
-	computepb.UnimplementedCalculatorAPIServer
-}
-
-//Divide ..
-func (s *server) Divide(ctx context.Context, req *computepb.DivideRequest) (*computepb.DivideResponse, error) {
-	fmt.Printf("\nRequest : %v", req)
-	if req.GetDivisor() == 0 {
-		return nil, status.Errorf(
-			codes.InvalidArgument,
-			fmt.Sprintf("Received an invalid number: %v", req.GetDivisor()))
-	}
-	result := req.GetDividend() / req.GetDividend()
-	return &computepb.DivideResponse{
-		Result: float64(result),
-	}, nil
-}
-
-func (s *server) Sum(ctx context.Context, req *computepb.SumRequest) (*computepb.SumResponse, error) {
-	fmt.Printf("Sum function was invoked with %v\n", req)
-	for i := 0; i < 3; i++ {
-		if ctx.Err() == context.DeadlineExceeded {
-			// the client canceled the request
-			fmt.Println("The client canceled the request!")
-			return nil, status.Error(codes.Canceled, "The client canceled the request")
-		}
-		time.Sleep(1 * time.Second)
-	}
-	result := req.GetNumber1() + req.GetNumber2()
-	res := &computepb.SumResponse{
-		Result: result,
-	}
-	return res, nil
-}
+var (
+	jwksFile       = flag.String("jwks", "", "JWKS file used to verify bearer tokens (e.g. ../../config/jwks.json)")
+	apiKeysFile    = flag.String("api-keys", "", "YAML file listing the accepted API keys (e.g. ../../config/api-keys.yaml)")
+	rbacPolicy     = flag.String("rbac-policy", "", "YAML policy restricting which roles may call which methods (e.g. ../../config/rbac.yaml)")
+	rateLimits     = flag.String("rate-limits", "", "YAML file with per-method rate limits and daily quotas (e.g. ../../config/ratelimit.yaml)")
+	chaosFile      = flag.String("chaos", "", "YAML file with the faults injected from startup (e.g. ../../config/chaos.yaml), changed at runtime through AdminAPI.SetChaos on the admin listener")
+	recordFile     = flag.String("record", "", "Binary log receiving the calls, messages and statuses, for the replay command; empty to disable")
+	recordMethods  = flag.String("record-methods", "/"+serviceName+"/", "Comma separated methods recorded, or services with a trailing slash")
+	logSample      = flag.Float64("log-sample", 1, "Fraction of successful calls written to the request log, failures are always logged")
+	logPayloads    = flag.Bool("log-payloads", false, "Include unary request and response messages in the request log")
+	logRedact      = flag.String("log-redact", "", "Comma separated proto field names masked in logged payloads (e.g. title,author)")
+	compressors    = flag.String("compression", compression.Zstd+","+compression.Gzip, "Comma separated compressors of the responses, by preference, used when the client accepts one; empty to answer like the request")
+	maxRecvSize    = flag.Int("max-recv-size", 4<<20, "Largest request message accepted, in bytes")
+	maxSendSize    = flag.Int("max-send-size", 4<<20, "Largest response message sent, in bytes")
+	keepaliveFile  = flag.String("keepalive", "", "YAML file with the keepalive pings, enforcement policy and connection age limits (e.g. ../../config/keepalive.yaml), the documented defaults when empty")
+	drainTimeout   = flag.Duration("drain-timeout", 30*time.Second, "How long in-flight calls may run after SIGTERM before they are aborted")
+	shutdownDelay  = flag.Duration("shutdown-delay", 5*time.Second, "How long the server keeps serving after SIGTERM with the health service NOT_SERVING, so load balancers stop sending calls, before it drains")
+	healthInterval = flag.Duration("health-interval", 5*time.Second, "How often the dependencies reported by the health service are probed")
+	healthFailures = flag.Int("health-failure-threshold", 1, "How many probes of a dependency must fail in a row before its services report NOT_SERVING")
+	metricsAddr    = flag.String("metrics-addr", ":9990", "Address of the HTTP listener serving Prometheus /metrics, empty to disable")
+	httpAddr       = flag.String("http-addr", ":9991", "Address of the REST/JSON gateway listener, empty to disable")
+	adminAddr      = flag.String("admin-addr", "localhost:9992", "Address of the admin listener serving AdminAPI, channelz, DiagnosticsAPI and, with -pprof, /debug/pprof/; keep it away from the clients, loopback only unless -jwks or -api-keys is set, empty to disable")
+	pprofEnabled   = flag.Bool("pprof", false, "Serve net/http/pprof on the admin listener to callers holding the admin role, needs -jwks or -api-keys")
+	webEnabled     = flag.Bool("web", true, "Also serve gRPC-Web and Connect (JSON and binary) to browsers on the gRPC port")
+	corsOrigins    = flag.String("cors-origins", "", "Comma separated origins allowed to make cross-origin browser calls (e.g. http://localhost:3000), * for any")
+	traceExporter  = flag.String("trace-exporter", "", "OpenTelemetry span exporter: otlp, stdout or file, empty to disable tracing")
+	traceEndpoint  = flag.String("trace-endpoint", "localhost:4317", "OTLP gRPC collector address used by the otlp exporter")
+	traceInsecure  = flag.Bool("trace-insecure", true, "Connect to the OTLP collector without TLS")
+	traceFile      = flag.String("trace-file", "traces.json", "File receiving the spans with the file exporter")
+	traceSample    = flag.Float64("trace-sample", 1, "Fraction of new traces that are recorded")
+)
 
 func main() {
+	flag.Parse()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
 	log.Printf("Compute service is running.......")
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
-	opts := []grpc.ServerOption{}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: "compute-service",
+		Exporter:    *traceExporter,
+		Endpoint:    *traceEndpoint,
+		Insecure:    *traceInsecure,
+		File:        *traceFile,
+		SampleRatio: *traceSample,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	opts := []grpc.ServerOption{
+		tracing.ServerOption(),
+		grpc.MaxRecvMsgSize(*maxRecvSize),
+		grpc.MaxSendMsgSize(*maxSendSize),
+	}
+	keepaliveCfg := keepalive.Default
+	if *keepaliveFile != "" {
+		cfg, err := keepalive.LoadConfig(*keepaliveFile)
+		if err != nil {
+			log.Fatalf("Failed loading keepalive config: %v", err)
+		}
+		keepaliveCfg = *cfg
+	}
+	opts = append(opts, keepaliveCfg.Server.ServerOptions()...)
+	services := []string{serviceName}
+	probes := []healthcheck.Probe{}
 	tlsEnabled := false
+	gatewayCreds := insecure.NewCredentials()
 	if tlsEnabled {
 		certFile := "../../ssl/server.crt"
 		keyFile := "../../ssl/server.pem"
@@ -90,10 +138,175 @@ func main() {
 			return
 		}
 		opts = append(opts, grpc.Creds(creds))
+		gatewayCreds, err = credentials.NewClientTLSFromFile("../../ssl/ca.crt", "")
+		if err != nil {
+			log.Fatalf("Error while loading CA trust certificate: %v", err)
+		}
+		probes = append(probes, healthcheck.CertificateProbe(services, certFile, keyFile))
+	}
+	logOpts := logging.Options{SampleRate: *logSample, LogPayloads: *logPayloads}
+	if *logRedact != "" {
+		logOpts.Redact = strings.Split(*logRedact, ",")
+	}
+	serverMetrics := metrics.NewServerMetrics()
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		serverMetrics.UnaryServerInterceptor(),
+		logging.UnaryServerInterceptor(logOpts),
+	}
+	shutdown := graceful.New(*drainTimeout, *shutdownDelay)
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		serverMetrics.StreamServerInterceptor(),
+		logging.StreamServerInterceptor(logOpts),
+	}
+	if *compressors != "" {
+		names := strings.Split(*compressors, ",")
+		if err := compression.Check(names...); err != nil {
+			log.Fatalf("Invalid -compression: %v", err)
+		}
+		unaryInterceptors = append(unaryInterceptors, compression.UnaryServerInterceptor(names...))
+		streamInterceptors = append(streamInterceptors, compression.StreamServerInterceptor(names...))
+	}
+	if *recordFile != "" {
+		recorder, err := recording.Open(*recordFile, recording.Options{Methods: strings.Split(*recordMethods, ",")})
+		if err != nil {
+			log.Fatalf("Failed to open the recording: %v", err)
+		}
+		defer recorder.Close()
+		// before authentication, so the rejected calls are recorded too
+		unaryInterceptors = append(unaryInterceptors, recorder.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, recorder.StreamServerInterceptor())
+	}
+	var authenticator *auth.Authenticator
+	if *jwksFile != "" || *apiKeysFile != "" {
+		authenticator, err = auth.LoadAuthenticator(*jwksFile, *apiKeysFile)
+		if err != nil {
+			log.Fatalf("Failed loading credentials: %v", err)
+		}
+		authenticator.Exempt(healthcheck.ServicePrefix)
+		unaryInterceptors = append(unaryInterceptors, auth.UnaryServerInterceptor(authenticator))
+		streamInterceptors = append(streamInterceptors, auth.StreamServerInterceptor(authenticator))
+	}
+	if *rbacPolicy != "" {
+		policy, err := rbac.LoadPolicy(*rbacPolicy)
+		if err != nil {
+			log.Fatalf("Failed loading RBAC policy: %v", err)
+		}
+		unaryInterceptors = append(unaryInterceptors, rbac.UnaryServerInterceptor(policy))
+		streamInterceptors = append(streamInterceptors, rbac.StreamServerInterceptor(policy))
+	}
+	adminServer := &admin.Server{}
+	if *rateLimits != "" {
+		cfg, err := ratelimit.LoadConfig(*rateLimits)
+		if err != nil {
+			log.Fatalf("Failed loading rate limits: %v", err)
+		}
+		adminServer.Limiter = ratelimit.New(cfg)
+		unaryInterceptors = append(unaryInterceptors, ratelimit.UnaryServerInterceptor(adminServer.Limiter))
+		streamInterceptors = append(streamInterceptors, ratelimit.StreamServerInterceptor(adminServer.Limiter))
+	}
+	// after the rate limiter, so invalid requests still count against the quotas
+	unaryInterceptors = append(unaryInterceptors, validation.UnaryServerInterceptor(calculator.ErrorDomain))
+	streamInterceptors = append(streamInterceptors, validation.StreamServerInterceptor(calculator.ErrorDomain))
+	// after validation, so the faults reach only valid calls; disabled unless
+	// -chaos or SetChaos enables it
+	adminServer.Chaos = chaos.New()
+	adminServer.Chaos.Exempt(healthcheck.ServicePrefix)
+	if *chaosFile != "" {
+		cfg, err := chaos.LoadConfig(*chaosFile)
+		if err != nil {
+			log.Fatalf("Failed loading chaos config: %v", err)
+		}
+		adminServer.Chaos.SetConfig(*cfg)
+	}
+	unaryInterceptors = append(unaryInterceptors, adminServer.Chaos.UnaryServerInterceptor())
+	streamInterceptors = append(streamInterceptors, adminServer.Chaos.StreamServerInterceptor())
+	// innermost, so the other interceptors see the calls aborted by the shutdown
+	unaryInterceptors = append(unaryInterceptors, shutdown.UnaryServerInterceptor())
+	streamInterceptors = append(streamInterceptors, shutdown.StreamServerInterceptor())
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+	if *metricsAddr != "" {
+		reg := metrics.NewRegistry(serverMetrics)
+		reg.MustRegister(calculator.Collectors()...)
+		metrics.Serve(*metricsAddr, reg)
+	}
+	if *adminAddr != "" {
+		// the connections and calls of this server, reported by DiagnosticsAPI
+		tracker := admin.NewTracker()
+		opts = append(opts, grpc.StatsHandler(tracker))
+		adminLis, err := net.Listen("tcp", *adminAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen on the admin port: %v", err)
+		}
+		adminGRPC, adminHTTP, err := admin.ServePort(adminLis, admin.PortOptions{
+			Admin:         adminServer,
+			Diagnostics:   admin.NewDiagnostics(tracker),
+			Authenticator: authenticator,
+			Pprof:         *pprofEnabled,
+		})
+		if err != nil {
+			log.Fatalf("Failed to serve the admin port: %v", err)
+		}
+		shutdown.OnDrain(func() {
+			go adminGRPC.GracefulStop()
+			if adminHTTP != nil {
+				go adminHTTP.Shutdown(context.Background())
+			}
+		})
 	}
 	s := grpc.NewServer(opts...)
-	computepb.RegisterCalculatorAPIServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
+	computepb.RegisterCalculatorAPIServer(s, &calculator.Server{SumDelay: 3 * time.Second})
+	// lets grpcurl and similar tools discover the services; callers still
+	// need credentials
+	reflection.Register(s)
+	for i := range probes {
+		probes[i].FailureThreshold = *healthFailures
+	}
+	checker := healthcheck.New(services, *healthInterval, probes...)
+	checker.Register(s)
+	go checker.Run(context.Background())
+	shutdown.OnShutdown(checker.Shutdown)
+	shutdown.OnDrain(checker.CloseWatches)
+	if *httpAddr != "" {
+		gw, err := gateway.NewHandler(context.Background(), "localhost"+port,
+			[]grpc.DialOption{
+				grpc.WithTransportCredentials(gatewayCreds),
+				grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(*maxSendSize)),
+			},
+			computepb.OpenAPI, computepb.RegisterCalculatorAPIHandlerFromEndpoint)
+		if err != nil {
+			log.Fatalf("Failed to start the REST gateway: %v", err)
+		}
+		httpServer := gateway.Serve(*httpAddr, gw)
+		// refuse new HTTP requests, the ones in flight drain with the gRPC calls
+		shutdown.OnDrain(func() { go httpServer.Shutdown(context.Background()) })
+	}
+	grpcLis := lis
+	// the connections are told apart by their first bytes, which TLS hides
+	if *webEnabled && !tlsEnabled {
+		conn, err := grpc.NewClient("localhost"+port,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(*maxSendSize)),
+		)
+		if err != nil {
+			log.Fatalf("Failed to connect the gRPC-Web/Connect handlers: %v", err)
+		}
+		c := computepb.NewCalculatorAPIClient(conn)
+		var origins []string
+		if *corsOrigins != "" {
+			origins = strings.Split(*corsOrigins, ",")
+		}
+		var webLis net.Listener
+		grpcLis, webLis = web.Split(lis)
+		webServer := web.Serve(webLis, web.NewHandler(origins,
+			web.Unary("/"+serviceName+"/Divide", c.Divide),
+			web.Unary("/"+serviceName+"/Sum", c.Sum),
+		))
+		shutdown.OnDrain(func() { go webServer.Shutdown(context.Background()) })
+	}
+	if err := shutdown.Serve(s, grpcLis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
 }
@@ -0,0 +1,449 @@
+// Package fake serves programmable fakes of the gRPC services, so the teams
+// depending on BookSearchAPI or CalculatorAPI can test their code against
+// scripted answers without running the real servers.
+//
+// A fake answers each call with the first expectation matching it: the
+// responses to send, the error to return and how long to wait before. It
+// records every call so tests can check what the code under test sent.
+//
+//	books := fake.BookSearchAPI()
+//	books.Expect(fake.Expectation{
+//		Method:    "GetBook",
+//		Request:   &bookpb.GetBookRequest{Title: "Java"},
+//		Responses: []proto.Message{&bookpb.GetBookResponse{Book: &bookpb.Book{Title: "Java"}}},
+//	})
+//	conn := fake.Serve(t, books)
+//
+// The fakes are built from the service descriptors rather than written per
+// service, so they follow the protos as they change. Scenarios written in
+// YAML (see LoadScenario) script them without Go code, and the fakeserver
+// command serves a scenario on a port.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Expectation scripts how a fake answers the calls to one method
+type Expectation struct {
+	// Method is the name of the method, e.g. "GetBook"
+	Method string
+	// Request, when set, must match the request of unary and server
+	// streaming calls, and each request of bidirectional streams: every
+	// field set in Request must hold the same value in the request
+	Request proto.Message
+	// Requests, when set, must match the requests of client streaming
+	// calls, one by one
+	Requests []proto.Message
+	// Responses are sent in order: the first one answers unary and client
+	// streaming calls, all of them are streamed otherwise. Bidirectional
+	// streams send them after each matching request.
+	Responses []proto.Message
+	// Err, when set, is returned after the responses are sent; unary and
+	// client streaming calls then send no response
+	Err error
+	// Delay is the latency before the first response or the error
+	Delay time.Duration
+	// Interval is the pause between streamed responses
+	Interval time.Duration
+	// Times limits how many calls the expectation answers, 0 for any.
+	// Verify reports the expectations answering fewer calls than Times.
+	Times int
+}
+
+// Call records a call received by a fake
+type Call struct {
+	// Method is the name of the method, e.g. "GetBook"
+	Method string
+	// Requests are the messages received, in order
+	Requests []proto.Message
+	// Err is the error returned to the caller, nil on success
+	Err error
+}
+
+// Server is a fake of one service
+type Server struct {
+	desc protoreflect.ServiceDescriptor
+
+	mu           sync.Mutex
+	expectations []*expectation
+	calls        []Call
+	unexpected   []string
+}
+
+type expectation struct {
+	Expectation
+	answered int
+}
+
+// New returns a fake of the service, answering every call with Unimplemented
+// until expectations are added
+func New(service protoreflect.ServiceDescriptor) *Server {
+	return &Server{desc: service}
+}
+
+// BookSearchAPI returns a fake of book.BookSearchAPI
+func BookSearchAPI() *Server {
+	return New(bookpb.File_bookpb_book_proto.Services().ByName("BookSearchAPI"))
+}
+
+// CalculatorAPI returns a fake of calculator.CalculatorAPI
+func CalculatorAPI() *Server {
+	return New(computepb.File_computepb_compute_proto.Services().ByName("CalculatorAPI"))
+}
+
+// ServiceName returns the fully qualified name of the service faked
+func (f *Server) ServiceName() string {
+	return string(f.desc.FullName())
+}
+
+// Expect adds an expectation, tried after the ones added before it
+func (f *Server) Expect(e Expectation) error {
+	if f.desc.Methods().ByName(protoreflect.Name(e.Method)) == nil {
+		return fmt.Errorf("%s has no method %q", f.desc.FullName(), e.Method)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expectations = append(f.expectations, &expectation{Expectation: e})
+	return nil
+}
+
+// Calls returns the calls received so far, in the order they ended
+func (f *Server) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// Reset drops the expectations and the calls recorded
+func (f *Server) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expectations, f.calls, f.unexpected = nil, nil, nil
+}
+
+// Verify reports the calls no expectation matched and the expectations with
+// Times set that answered fewer calls
+func (f *Server) Verify() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for _, u := range f.unexpected {
+		errs = append(errs, errors.New(u))
+	}
+	for i, e := range f.expectations {
+		if e.Times > 0 && e.answered < e.Times {
+			errs = append(errs, fmt.Errorf("%s/%s: expectation %d answered %d of %d calls", f.desc.FullName(), e.Method, i, e.answered, e.Times))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Register serves the fake on s
+func (f *Server) Register(s grpc.ServiceRegistrar) {
+	s.RegisterService(f.serviceDesc(), f)
+}
+
+// Serve serves the fakes in-process over bufconn until the end of the test,
+// and returns a connection to them. The test fails if Verify reports an
+// error once it is over.
+func Serve(tb testing.TB, fakes ...*Server) *grpc.ClientConn {
+	tb.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	for _, f := range fakes {
+		f.Register(s)
+	}
+	go s.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		s.Stop()
+		tb.Fatalf("connecting to the fakes: %v", err)
+	}
+	tb.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+		for _, f := range fakes {
+			if err := f.Verify(); err != nil {
+				tb.Errorf("fake %s: %v", f.ServiceName(), err)
+			}
+		}
+	})
+	return conn
+}
+
+// serviceDesc describes the service to grpc, with a handler per method
+func (f *Server) serviceDesc() *grpc.ServiceDesc {
+	sd := &grpc.ServiceDesc{
+		ServiceName: string(f.desc.FullName()),
+		// any type implements interface{}, so grpc accepts the fake
+		HandlerType: (*interface{})(nil),
+		Metadata:    f.desc.ParentFile().Path(),
+	}
+	methods := f.desc.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if !md.IsStreamingClient() && !md.IsStreamingServer() {
+			sd.Methods = append(sd.Methods, grpc.MethodDesc{
+				MethodName: string(md.Name()),
+				Handler:    f.unaryHandler(md),
+			})
+			continue
+		}
+		sd.Streams = append(sd.Streams, grpc.StreamDesc{
+			StreamName:    string(md.Name()),
+			Handler:       f.streamHandler(md),
+			ServerStreams: md.IsStreamingServer(),
+			ClientStreams: md.IsStreamingClient(),
+		})
+	}
+	return sd
+}
+
+func (f *Server) unaryHandler(md protoreflect.MethodDescriptor) grpc.MethodHandler {
+	return func(_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := NewMessage(md.Input())
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return f.unary(ctx, md, req.(proto.Message))
+		}
+		if interceptor == nil {
+			return handler(ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: f, FullMethod: fmt.Sprintf("/%s/%s", f.desc.FullName(), md.Name())}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+func (f *Server) unary(ctx context.Context, md protoreflect.MethodDescriptor, req proto.Message) (_ proto.Message, err error) {
+	defer f.record(md, []proto.Message{req}, &err)
+	e := f.match(md, func(e *expectation) bool { return Matches(e.Request, req) })
+	if e == nil {
+		return nil, f.unmatched(md, req)
+	}
+	if err := sleep(ctx, e.Delay); err != nil {
+		return nil, err
+	}
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	return firstResponse(md, e), nil
+}
+
+func (f *Server) streamHandler(md protoreflect.MethodDescriptor) grpc.StreamHandler {
+	return func(_ interface{}, stream grpc.ServerStream) (err error) {
+		var reqs []proto.Message
+		defer func() { f.record(md, reqs, &err) }()
+		switch {
+		case md.IsStreamingClient() && md.IsStreamingServer():
+			return f.bidi(md, stream, &reqs)
+		case md.IsStreamingClient():
+			return f.clientStream(md, stream, &reqs)
+		default:
+			return f.serverStream(md, stream, &reqs)
+		}
+	}
+}
+
+func (f *Server) serverStream(md protoreflect.MethodDescriptor, stream grpc.ServerStream, reqs *[]proto.Message) error {
+	req := NewMessage(md.Input())
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	*reqs = append(*reqs, req)
+	e := f.match(md, func(e *expectation) bool { return Matches(e.Request, req) })
+	if e == nil {
+		return f.unmatched(md, req)
+	}
+	return respond(stream, e)
+}
+
+func (f *Server) clientStream(md protoreflect.MethodDescriptor, stream grpc.ServerStream, reqs *[]proto.Message) error {
+	for {
+		req := NewMessage(md.Input())
+		err := stream.RecvMsg(req)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		*reqs = append(*reqs, req)
+	}
+	e := f.match(md, func(e *expectation) bool {
+		if e.Requests == nil {
+			return true
+		}
+		if len(e.Requests) != len(*reqs) {
+			return false
+		}
+		for i, want := range e.Requests {
+			if !Matches(want, (*reqs)[i]) {
+				return false
+			}
+		}
+		return true
+	})
+	if e == nil {
+		return f.unmatched(md, *reqs...)
+	}
+	if err := sleep(stream.Context(), e.Delay); err != nil {
+		return err
+	}
+	if e.Err != nil {
+		return e.Err
+	}
+	return stream.SendMsg(firstResponse(md, e))
+}
+
+func (f *Server) bidi(md protoreflect.MethodDescriptor, stream grpc.ServerStream, reqs *[]proto.Message) error {
+	for {
+		req := NewMessage(md.Input())
+		err := stream.RecvMsg(req)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		*reqs = append(*reqs, req)
+		e := f.match(md, func(e *expectation) bool { return Matches(e.Request, req) })
+		if e == nil {
+			return f.unmatched(md, req)
+		}
+		if err := respond(stream, e); err != nil {
+			return err
+		}
+	}
+}
+
+// respond streams the responses of e, then returns its error
+func respond(stream grpc.ServerStream, e *expectation) error {
+	if err := sleep(stream.Context(), e.Delay); err != nil {
+		return err
+	}
+	for i, res := range e.Responses {
+		if i > 0 {
+			if err := sleep(stream.Context(), e.Interval); err != nil {
+				return err
+			}
+		}
+		if err := stream.SendMsg(res); err != nil {
+			return err
+		}
+	}
+	return e.Err
+}
+
+// match returns the first expectation of md accepted by ok that can answer
+// another call, counting the call
+func (f *Server) match(md protoreflect.MethodDescriptor, ok func(*expectation) bool) *expectation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, e := range f.expectations {
+		if e.Method != string(md.Name()) || (e.Times > 0 && e.answered >= e.Times) || !ok(e) {
+			continue
+		}
+		e.answered++
+		return e
+	}
+	return nil
+}
+
+// unmatched records and reports a call no expectation matches
+func (f *Server) unmatched(md protoreflect.MethodDescriptor, reqs ...proto.Message) error {
+	var texts []string
+	for _, req := range reqs {
+		texts = append(texts, "{"+prototext.MarshalOptions{}.Format(req)+"}")
+	}
+	msg := fmt.Sprintf("fake: no expectation of %s/%s matches %s", f.desc.FullName(), md.Name(), strings.Join(texts, ", "))
+	f.mu.Lock()
+	f.unexpected = append(f.unexpected, msg)
+	f.mu.Unlock()
+	return status.Error(codes.Unimplemented, msg)
+}
+
+func (f *Server) record(md protoreflect.MethodDescriptor, reqs []proto.Message, err *error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, Call{Method: string(md.Name()), Requests: reqs, Err: *err})
+}
+
+// firstResponse returns the response answering a unary or client streaming
+// call, an empty message when e has none
+func firstResponse(md protoreflect.MethodDescriptor, e *expectation) proto.Message {
+	if len(e.Responses) == 0 {
+		return NewMessage(md.Output())
+	}
+	return e.Responses[0]
+}
+
+// sleep waits for d, unless the call ends before
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// NewMessage returns an empty message of the type, using the generated Go
+// type when it is linked in so that fakes and tests compare the same types
+func NewMessage(md protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+	return dynamicpb.NewMessage(md)
+}
+
+// Matches reports whether every field set in want holds the same value in
+// got. Nested messages and lists must be equal as a whole. A nil want matches
+// any message.
+func Matches(want, got proto.Message) bool {
+	if want == nil {
+		return true
+	}
+	g := got.ProtoReflect()
+	if want.ProtoReflect().Descriptor().FullName() != g.Descriptor().FullName() {
+		return false
+	}
+	ok := true
+	want.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		gfd := g.Descriptor().Fields().ByNumber(fd.Number())
+		ok = gfd != nil && g.Has(gfd) && g.Get(gfd).Equal(v)
+		return ok
+	})
+	return ok
+}
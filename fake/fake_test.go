@@ -0,0 +1,92 @@
+package fake_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/fake"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBookSearchAPI(t *testing.T) {
+	books := fake.BookSearchAPI()
+	java := &bookpb.Book{Title: "Java", Author: "Herbert Schildt"}
+	for _, e := range []fake.Expectation{
+		{Method: "GetBook", Request: &bookpb.GetBookRequest{Title: "Java"}, Responses: []proto.Message{&bookpb.GetBookResponse{Book: java}}, Times: 1},
+		{Method: "GetBook", Err: apierror.Domain("book-search-service").NotFound("BOOK_NOT_FOUND", "book.Book", "", "no such book")},
+		{Method: "GetAllBooks", Responses: []proto.Message{&bookpb.GetAllBooksResponse{Book: java}, &bookpb.GetAllBooksResponse{Book: java}}, Err: status.Error(codes.Unavailable, "gone")},
+	} {
+		if err := books.Expect(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := bookpb.NewBookSearchAPIClient(fake.Serve(t, books))
+	ctx := context.Background()
+
+	res, err := client.GetBook(ctx, &bookpb.GetBookRequest{Title: "Java"})
+	if err != nil || res.GetBook().GetAuthor() != "Herbert Schildt" {
+		t.Fatalf("got %v, %v", res, err)
+	}
+	// the first expectation answered its one call
+	_, err = client.GetBook(ctx, &bookpb.GetBookRequest{Title: "Java"})
+	if apierror.Reason(err) != "BOOK_NOT_FOUND" {
+		t.Errorf("got %v, want BOOK_NOT_FOUND", err)
+	}
+
+	stream, err := client.GetAllBooks(ctx, &bookpb.GetAllBooksRequest{Title: "anything"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := 0
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+		received++
+	}
+	if received != 2 || status.Code(err) != codes.Unavailable {
+		t.Errorf("got %d books and %v, want 2 books and Unavailable", received, err)
+	}
+
+	calls := books.Calls()
+	if len(calls) != 3 || calls[2].Method != "GetAllBooks" || !proto.Equal(calls[2].Requests[0], &bookpb.GetAllBooksRequest{Title: "anything"}) {
+		t.Errorf("unexpected calls %v", calls)
+	}
+}
+
+func TestUnexpectedCall(t *testing.T) {
+	books := fake.BookSearchAPI()
+	conn := fake.Serve(t, books)
+	each, err := bookpb.NewBookSearchAPIClient(conn).GetEachBook(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	each.Send(&bookpb.GetEachBookRequest{Title: "Java"})
+	if _, err := each.Recv(); status.Code(err) != codes.Unimplemented {
+		t.Errorf("got %v, want Unimplemented", err)
+	}
+	if books.Verify() == nil {
+		t.Error("Verify reported no error after an unexpected call")
+	}
+	// the unexpected call is wanted here, keep Serve from failing the test
+	books.Reset()
+}
+
+func TestScenario(t *testing.T) {
+	sc, err := fake.LoadScenario("../config/fake-scenario.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakes, err := sc.Servers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fakes) != 2 || fakes[0].ServiceName() != "book.BookSearchAPI" || fakes[1].ServiceName() != "calculator.CalculatorAPI" {
+		t.Errorf("got fakes %v", fakes)
+	}
+}
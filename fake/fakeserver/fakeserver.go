@@ -0,0 +1,81 @@
+// Command fakeserver serves the fakes scripted by a YAML scenario, so the
+// clients of BookSearchAPI and CalculatorAPI can run against canned answers:
+//
+//	go run fakeserver.go -scenario ../../config/fake-scenario.yaml
+//
+// Every call is logged. On shutdown the calls no expectation matched and the
+// expectations answering fewer calls than their times are reported, and the
+// command exits with status 1 if there are any.
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/fake"
+	"github.com/vpulimamidi/grpc-go-course/graceful"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+var (
+	scenarioFile = flag.String("scenario", "", "YAML scenario scripting the fakes (e.g. ../../config/fake-scenario.yaml)")
+	listenAddr   = flag.String("addr", ":8989", "Address of the gRPC listener")
+	logPayloads  = flag.Bool("log-payloads", true, "Log the unary request and response messages")
+)
+
+func main() {
+	flag.Parse()
+	if *scenarioFile == "" {
+		log.Fatalf("-scenario is required")
+	}
+	sc, err := fake.LoadScenario(*scenarioFile)
+	if err != nil {
+		log.Fatalf("Failed loading the scenario: %v", err)
+	}
+	fakes, err := sc.Servers()
+	if err != nil {
+		log.Fatalf("Invalid scenario: %v", err)
+	}
+	logOpts := logging.Options{SampleRate: 1, LogPayloads: *logPayloads}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor(logOpts)),
+		grpc.ChainStreamInterceptor(logging.StreamServerInterceptor(logOpts)),
+	)
+	healthServer := health.NewServer()
+	for _, f := range fakes {
+		f.Register(s)
+		healthServer.SetServingStatus(f.ServiceName(), healthpb.HealthCheckResponse_SERVING)
+		slog.Info("faking", "service", f.ServiceName())
+	}
+	healthpb.RegisterHealthServer(s, healthServer)
+	reflection.Register(s)
+
+	lis, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	log.Printf("Serving the fakes on %s", lis.Addr())
+	if err := graceful.New(5*time.Second, 0).Serve(s, lis); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+
+	failed := false
+	for _, f := range fakes {
+		slog.Info("calls received", "service", f.ServiceName(), "calls", len(f.Calls()))
+		if err := f.Verify(); err != nil {
+			slog.Error("scenario not met", "service", f.ServiceName(), "error", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,168 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario scripts fakes from YAML. Messages are written as their JSON
+// mapping, with the field names of the protos or their camelCase form:
+//
+//	expectations:
+//	  - method: book.BookSearchAPI/GetBook
+//	    request: {title: Java}
+//	    responses:
+//	      - book: {title: Java, author: Herbert Schildt}
+//	    delay: 200ms
+//	  - method: book.BookSearchAPI/GetBook
+//	    error: {code: NOT_FOUND, message: no such book, reason: BOOK_NOT_FOUND, domain: book-search-service}
+//	    times: 1
+type Scenario struct {
+	Expectations []ScenarioExpectation `yaml:"expectations"`
+}
+
+// ScenarioExpectation is an Expectation of a scenario
+type ScenarioExpectation struct {
+	// Method is the fully qualified method, e.g. book.BookSearchAPI/GetBook
+	Method    string         `yaml:"method"`
+	Request   interface{}    `yaml:"request"`
+	Requests  []interface{}  `yaml:"requests"`
+	Responses []interface{}  `yaml:"responses"`
+	Error     *ScenarioError `yaml:"error"`
+	Delay     time.Duration  `yaml:"delay"`
+	Interval  time.Duration  `yaml:"interval"`
+	Times     int            `yaml:"times"`
+}
+
+// ScenarioError is the error returned by an expectation. With a reason it
+// follows the error model of the services, with an ErrorInfo in domain.
+type ScenarioError struct {
+	// Code is the canonical code, e.g. NOT_FOUND
+	Code     string            `yaml:"code"`
+	Message  string            `yaml:"message"`
+	Reason   string            `yaml:"reason"`
+	Domain   string            `yaml:"domain"`
+	Metadata map[string]string `yaml:"metadata"`
+}
+
+// LoadScenario reads a YAML scenario from disk
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc := &Scenario{}
+	if err := yaml.Unmarshal(data, sc); err != nil {
+		return nil, fmt.Errorf("parsing scenario: %w", err)
+	}
+	return sc, nil
+}
+
+// Servers returns a fake per service of the scenario, in order of first
+// appearance, scripted with its expectations
+func (sc *Scenario) Servers() ([]*Server, error) {
+	var fakes []*Server
+	byService := map[protoreflect.FullName]*Server{}
+	for i, se := range sc.Expectations {
+		service, method, ok := strings.Cut(strings.TrimPrefix(se.Method, "/"), "/")
+		if !ok {
+			return nil, fmt.Errorf("expectation %d: method %q is not service/method", i, se.Method)
+		}
+		f := byService[protoreflect.FullName(service)]
+		if f == nil {
+			d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+			if err != nil {
+				return nil, fmt.Errorf("expectation %d: unknown service %s", i, service)
+			}
+			sd, ok := d.(protoreflect.ServiceDescriptor)
+			if !ok {
+				return nil, fmt.Errorf("expectation %d: %s is not a service", i, service)
+			}
+			f = New(sd)
+			byService[sd.FullName()] = f
+			fakes = append(fakes, f)
+		}
+		e, err := se.expectation(f.desc.Methods().ByName(protoreflect.Name(method)))
+		if err == nil {
+			e.Method = method
+			err = f.Expect(e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("expectation %d (%s): %w", i, se.Method, err)
+		}
+	}
+	return fakes, nil
+}
+
+func (se ScenarioExpectation) expectation(md protoreflect.MethodDescriptor) (Expectation, error) {
+	e := Expectation{Delay: se.Delay, Interval: se.Interval, Times: se.Times}
+	if md == nil {
+		return e, fmt.Errorf("unknown method")
+	}
+	var err error
+	if se.Request != nil {
+		if e.Request, err = message(md.Input(), se.Request); err != nil {
+			return e, fmt.Errorf("request: %w", err)
+		}
+	}
+	for i, r := range se.Requests {
+		req, err := message(md.Input(), r)
+		if err != nil {
+			return e, fmt.Errorf("request %d: %w", i, err)
+		}
+		e.Requests = append(e.Requests, req)
+	}
+	for i, r := range se.Responses {
+		res, err := message(md.Output(), r)
+		if err != nil {
+			return e, fmt.Errorf("response %d: %w", i, err)
+		}
+		e.Responses = append(e.Responses, res)
+	}
+	if se.Error != nil {
+		if e.Err, err = se.Error.err(); err != nil {
+			return e, fmt.Errorf("error: %w", err)
+		}
+	}
+	return e, nil
+}
+
+func (se *ScenarioError) err() (error, error) {
+	var code codes.Code
+	if err := code.UnmarshalJSON([]byte(strconv.Quote(se.Code))); err != nil {
+		return nil, err
+	}
+	if code == codes.OK {
+		return nil, fmt.Errorf("code must not be OK")
+	}
+	if se.Reason == "" {
+		return status.Error(code, se.Message), nil
+	}
+	return apierror.Domain(se.Domain).Error(code, se.Reason, se.Message, se.Metadata), nil
+}
+
+// message converts a message decoded from YAML to the proto type
+func message(md protoreflect.MessageDescriptor, v interface{}) (proto.Message, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := NewMessage(md)
+	if err := protojson.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,138 @@
+// Package gateway serves the REST/JSON mapping of the gRPC services, generated
+// by grpc-gateway from the google.api.http annotations of the protos, along
+// with the OpenAPI document describing it.
+//
+// Calls are forwarded to the gRPC server, so they go through the same
+// authentication, authorization, rate limiting and logging as native gRPC
+// calls. Server streaming responses are written as newline delimited JSON,
+// and client streaming requests are read the same way.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/ratelimit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NDJSON is the content type of streamed responses
+const NDJSON = "application/x-ndjson"
+
+// RegisterFunc registers the handlers of one service, forwarding to endpoint,
+// e.g. bookpb.RegisterBookSearchAPIHandlerFromEndpoint
+type RegisterFunc func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error
+
+// forwardedHeaders are passed to the gRPC server as metadata, on top of the
+// Authorization header forwarded by grpc-gateway
+var forwardedHeaders = []string{auth.APIKeyHeader, "traceparent", "tracestate", "baggage"}
+
+// NewHandler returns the gateway for the services registered by register,
+// forwarding the calls to the gRPC server at endpoint. The OpenAPI document
+// is served at /openapi.json when openAPI is not empty. The connections to
+// the endpoint are closed when ctx is done.
+func NewHandler(ctx context.Context, endpoint string, dialOpts []grpc.DialOption, openAPI []byte, register ...RegisterFunc) (http.Handler, error) {
+	gw := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithMetadata(clientAddr),
+		runtime.WithErrorHandler(errorHandler),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &jsonMarshaler{&runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}}),
+	)
+	for _, r := range register {
+		if err := r(ctx, gw, endpoint, dialOpts); err != nil {
+			return nil, err
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", fullDuplex(gw))
+	if len(openAPI) > 0 {
+		mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(openAPI)
+		})
+	}
+	return mux, nil
+}
+
+// Serve starts an HTTP listener on addr serving h in the background
+func Serve(addr string, h http.Handler) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: h, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("REST gateway listener failed: %v", err)
+		}
+	}()
+	return srv
+}
+
+func headerMatcher(key string) (string, bool) {
+	for _, h := range forwardedHeaders {
+		if strings.EqualFold(key, h) {
+			return h, true
+		}
+	}
+	name, ok := runtime.DefaultHeaderMatcher(key)
+	// only the gateway sets the address of the client
+	if ok && strings.EqualFold(name, ratelimit.ClientAddrKey) {
+		return "", false
+	}
+	return name, ok
+}
+
+// clientAddr passes the address of the HTTP client to the gRPC server, which
+// only sees the loopback connection of the gateway
+func clientAddr(ctx context.Context, r *http.Request) metadata.MD {
+	return metadata.Pairs(ratelimit.ClientAddrKey, ratelimit.Host(r.RemoteAddr))
+}
+
+// errorHandler writes the gRPC status as JSON with the HTTP status of its
+// code (NotFound → 404, InvalidArgument → 400, Unauthenticated → 401,
+// PermissionDenied → 403, ResourceExhausted → 429, Unavailable → 503, ...),
+// and turns a RetryInfo detail into a Retry-After header. Streams failing
+// before their first message get the same status code, with the error as
+// the only line of the body.
+func errorHandler(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	for _, d := range status.Convert(err).Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok {
+			seconds := math.Ceil(ri.GetRetryDelay().AsDuration().Seconds())
+			w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+		}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
+// fullDuplex lets the bidirectional streaming handlers write responses
+// while HTTP/1.1 clients are still sending the request body
+func fullDuplex(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 1 {
+			http.NewResponseController(w).EnableFullDuplex()
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// jsonMarshaler is the default protojson marshaler, labelling streamed
+// responses as newline delimited JSON
+type jsonMarshaler struct {
+	*runtime.JSONPb
+}
+
+func (m *jsonMarshaler) StreamContentType(v interface{}) string {
+	return NDJSON
+}
@@ -0,0 +1,199 @@
+// Package graceful serves a gRPC server until SIGINT or SIGTERM, then drains
+// the in-flight calls before stopping.
+package graceful
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// abortGrace is how long aborted streams get to return their error before
+// the connections are closed
+const abortGrace = 2 * time.Second
+
+// Server serves a grpc.Server with signal handling and connection draining.
+//
+// On SIGINT, SIGTERM or a call to Shutdown the shutdown hooks run (e.g. to mark the health
+// service NOT_SERVING) and the server keeps serving for the shutdown delay,
+// giving load balancers and health-checking clients time to see the change.
+// The drain hooks then run (e.g. to end the health watches, which never end
+// on their own) and GracefulStop lets in-flight calls finish. Calls still
+// running once the drain timeout expires are aborted with codes.Unavailable
+// and the server is stopped. A second signal terminates the process
+// immediately.
+type Server struct {
+	drainTimeout time.Duration
+	delay        time.Duration
+	hooks        []func()
+	drainHooks   []func()
+
+	abort       context.Context
+	cancelAbort context.CancelFunc
+
+	// shutdown is closed by Shutdown
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// New returns a Server waiting delay after the shutdown hooks, then draining
+// in-flight calls for at most drainTimeout
+func New(drainTimeout, delay time.Duration) *Server {
+	abort, cancel := context.WithCancel(context.Background())
+	return &Server{drainTimeout: drainTimeout, delay: delay, abort: abort, cancelAbort: cancel, shutdown: make(chan struct{})}
+}
+
+// Shutdown starts the shutdown of the server as a signal does, and returns
+// at once; Serve returns when the server has stopped
+func (g *Server) Shutdown() {
+	g.shutdownOnce.Do(func() { close(g.shutdown) })
+}
+
+// OnShutdown registers f to run as soon as a shutdown signal is received,
+// before the server starts draining
+func (g *Server) OnShutdown(f func()) {
+	g.hooks = append(g.hooks, f)
+}
+
+// OnDrain registers f to run once the shutdown delay has elapsed, right
+// before the server starts draining
+func (g *Server) OnDrain(f func()) {
+	g.drainHooks = append(g.drainHooks, f)
+}
+
+// Serve runs s on lis until a shutdown signal is received and s has drained.
+// It returns the error of grpc.Server.Serve, if any.
+func (g *Server) Serve(s *grpc.Server, lis net.Listener) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(lis)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	case <-g.shutdown:
+	}
+	// restore the default behaviour so a second signal kills the process
+	stop()
+
+	for _, f := range g.hooks {
+		f()
+	}
+	if g.delay > 0 {
+		log.Printf("Shutting down, serving for %s before draining", g.delay)
+		time.Sleep(g.delay)
+	}
+	log.Printf("Draining in-flight calls for up to %s", g.drainTimeout)
+	for _, f := range g.drainHooks {
+		f()
+	}
+	drained := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		log.Printf("All calls completed")
+	case <-time.After(g.drainTimeout):
+		log.Printf("Drain timeout expired, aborting the remaining calls")
+		g.cancelAbort()
+		select {
+		case <-drained:
+		case <-time.After(abortGrace):
+		}
+		s.Stop()
+	}
+	return <-errc
+}
+
+// errAborted ends the calls still running when the drain timeout expires
+var errAborted = status.Error(codes.Unavailable, "server is shutting down")
+
+// UnaryServerInterceptor cancels the context of the unary calls still running
+// when the drain timeout expires, and reports them to the client as
+// codes.Unavailable so it can retry on another server. Calls that complete on
+// their own keep their result.
+func (g *Server) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stopAbort := context.AfterFunc(g.abort, cancel)
+		defer stopAbort()
+
+		res, err := handler(ctx, req)
+		if err != nil && g.abort.Err() != nil {
+			return nil, errAborted
+		}
+		return res, err
+	}
+}
+
+// StreamServerInterceptor does the same for streams: their context is
+// cancelled and a RecvMsg blocked waiting for the client returns
+// codes.Unavailable, so the handlers end whether they send or receive.
+func (g *Server) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := context.WithCancel(ss.Context())
+		defer cancel()
+		stopAbort := context.AfterFunc(g.abort, cancel)
+		defer stopAbort()
+
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx, abort: g.abort})
+		if err != nil && g.abort.Err() != nil {
+			return errAborted
+		}
+		return err
+	}
+}
+
+// serverStream overrides the context of the wrapped stream and stops waiting
+// for messages once abort is done
+type serverStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	abort context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return s.ServerStream.RecvMsg(m)
+	}
+	// the message is received into a copy: once abandoned, the pending
+	// RecvMsg must not write to m, which belongs to the handler again
+	received := msg.ProtoReflect().New().Interface()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.ServerStream.RecvMsg(received)
+	}()
+	select {
+	case err := <-errc:
+		if err != nil {
+			return err
+		}
+		proto.Reset(msg)
+		proto.Merge(msg, received)
+		return nil
+	case <-s.abort.Done():
+		return errAborted
+	}
+}
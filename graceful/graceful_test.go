@@ -0,0 +1,87 @@
+package graceful_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/booksearch"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/calculator"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/graceful"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const drainTimeout = 300 * time.Millisecond
+
+func TestAbortAfterDrainTimeout(t *testing.T) {
+	g := graceful.New(drainTimeout, 0)
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(g.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(g.StreamServerInterceptor()),
+	)
+	bookpb.RegisterBookSearchAPIServer(s, &booksearch.Server{Store: booksearch.NewStore(booksearch.SampleBooks())})
+	computepb.RegisterCalculatorAPIServer(s, &calculator.Server{SumDelay: time.Hour})
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- g.Serve(s, lis) }()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	// the handler waits in Recv for titles that never come
+	stream, err := bookpb.NewBookSearchAPIClient(conn).GetBooksForGivenTitles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&bookpb.GetBooksForGivenTitlesRequest{Title: "Java"}); err != nil {
+		t.Fatal(err)
+	}
+	streamErr := make(chan error, 1)
+	go func() { streamErr <- stream.RecvMsg(&bookpb.GetBooksForGivenTitlesResponse{}) }()
+	// the handler waits for its context
+	sumErr := make(chan error, 1)
+	go func() {
+		_, err := computepb.NewCalculatorAPIClient(conn).Sum(ctx, &computepb.SumRequest{Number1: 1, Number2: 2})
+		sumErr <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	g.Shutdown()
+	// the calls must end on the abort, well before the server is stopped
+	// abortGrace (2s) later
+	for name, errc := range map[string]chan error{"GetBooksForGivenTitles": streamErr, "Sum": sumErr} {
+		select {
+		case err := <-errc:
+			if st := status.Convert(err); st.Code() != codes.Unavailable || st.Message() != "server is shutting down" {
+				t.Errorf("%s: got %v, want Unavailable from the abort", name, err)
+			}
+		case <-time.After(drainTimeout + time.Second):
+			t.Fatalf("%s still running %s after the shutdown", name, time.Since(start))
+		}
+	}
+	if elapsed := time.Since(start); elapsed < drainTimeout {
+		t.Errorf("calls aborted after %s, want them to run for the drain timeout of %s", elapsed, drainTimeout)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Serve did not return after the calls were aborted")
+	}
+}
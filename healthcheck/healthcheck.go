@@ -0,0 +1,239 @@
+// Package healthcheck drives the standard grpc.health.v1.Health service from
+// dependency probes, so each service reports NOT_SERVING while one of its
+// dependencies is unavailable.
+package healthcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// ServicePrefix prefixes the methods of the health service, which
+// orchestrators call without credentials
+const ServicePrefix = "/grpc.health.v1.Health/"
+
+// Probe checks one dependency shared by the listed services
+type Probe struct {
+	// Name identifies the dependency in logs
+	Name string
+	// Services are the fully qualified service names depending on the probe,
+	// e.g. "book.BookSearchAPI"
+	Services []string
+	// Check returns an error while the dependency is unavailable
+	Check func(ctx context.Context) error
+	// FailureThreshold is the number of checks in a row that must fail
+	// before the services are marked NOT_SERVING, so a single slow or
+	// flaky check does not take them out; 1 when not set. A probe failing
+	// before its first success marks them NOT_SERVING right away.
+	FailureThreshold int
+}
+
+func (p Probe) threshold() int {
+	if p.FailureThreshold > 0 {
+		return p.FailureThreshold
+	}
+	return 1
+}
+
+// Checker periodically runs the probes and publishes the resulting statuses.
+// The overall status, reported for the empty service name, is SERVING only
+// when every service is.
+type Checker struct {
+	server   *health.Server
+	services []string
+	probes   []Probe
+	interval time.Duration
+
+	mu sync.Mutex
+	// failures holds the errors of the probes past their threshold, and
+	// streaks the number of checks in a row each probe failed
+	failures map[string]error
+	streaks  map[string]int
+	shutdown bool
+
+	// closing is cancelled by CloseWatches to end the open Watch streams
+	closing      context.Context
+	closeWatches context.CancelFunc
+}
+
+// New returns a Checker reporting the statuses of services. Services start as
+// NOT_SERVING until the first round of probes completes.
+func New(services []string, interval time.Duration, probes ...Probe) *Checker {
+	closing, closeWatches := context.WithCancel(context.Background())
+	c := &Checker{
+		server:       health.NewServer(),
+		services:     services,
+		probes:       probes,
+		interval:     interval,
+		failures:     make(map[string]error),
+		streaks:      make(map[string]int, len(probes)),
+		closing:      closing,
+		closeWatches: closeWatches,
+	}
+	for _, p := range probes {
+		c.streaks[p.Name] = p.threshold() - 1
+	}
+	c.server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	for _, svc := range services {
+		c.server.SetServingStatus(svc, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return c
+}
+
+// Register adds the health service to s
+func (c *Checker) Register(s grpc.ServiceRegistrar) {
+	healthpb.RegisterHealthServer(s, &healthServer{Server: c.server, closing: c.closing})
+}
+
+// Run probes the dependencies until ctx is done
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.CheckNow(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckNow runs every probe once and updates the statuses. Watchers are only
+// notified when a status changes.
+func (c *Checker) CheckNow(ctx context.Context) {
+	results := make(map[string]error, len(c.probes))
+	for _, p := range c.probes {
+		pctx, cancel := context.WithTimeout(ctx, c.interval)
+		results[p.Name] = p.Check(pctx)
+		cancel()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shutdown {
+		return
+	}
+	unhealthy := make(map[string]bool)
+	for _, p := range c.probes {
+		err := results[p.Name]
+		if err == nil {
+			if c.failures[p.Name] != nil {
+				log.Printf("Dependency %s recovered", p.Name)
+			}
+			delete(c.failures, p.Name)
+			c.streaks[p.Name] = 0
+			continue
+		}
+		c.streaks[p.Name]++
+		if c.streaks[p.Name] < p.threshold() {
+			log.Printf("Dependency %s failed %d of %d checks in a row: %v", p.Name, c.streaks[p.Name], p.threshold(), err)
+			continue
+		}
+		if c.failures[p.Name] == nil {
+			log.Printf("Dependency %s is unavailable: %v", p.Name, err)
+		}
+		c.failures[p.Name] = err
+		for _, svc := range p.Services {
+			unhealthy[svc] = true
+		}
+	}
+	overall := healthpb.HealthCheckResponse_SERVING
+	for _, svc := range c.services {
+		st := healthpb.HealthCheckResponse_SERVING
+		if unhealthy[svc] {
+			st = healthpb.HealthCheckResponse_NOT_SERVING
+			overall = st
+		}
+		c.server.SetServingStatus(svc, st)
+	}
+	c.server.SetServingStatus("", overall)
+}
+
+// Shutdown marks every service NOT_SERVING for good, so load balancers stop
+// sending new calls while the server drains
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shutdown = true
+	c.server.Shutdown()
+}
+
+// CloseWatches ends the open Watch streams with codes.Unavailable, and the
+// ones started later right away. Watches never end on their own, so they
+// would hold GracefulStop until the drain timeout; call it after Shutdown
+// once the watchers had time to see NOT_SERVING.
+func (c *Checker) CloseWatches() {
+	c.closeWatches()
+}
+
+// healthServer ends the Watch streams of health.Server once closing is done
+type healthServer struct {
+	*health.Server
+	closing context.Context
+}
+
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if h.closing.Err() != nil {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	stop := context.AfterFunc(h.closing, cancel)
+	defer stop()
+
+	err := h.Server.Watch(req, &watchStream{Health_WatchServer: stream, ctx: ctx})
+	if h.closing.Err() != nil {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	return err
+}
+
+// watchStream overrides the context of the wrapped stream
+type watchStream struct {
+	healthpb.Health_WatchServer
+	ctx context.Context
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+// CertificateProbe checks that the key pair can be loaded and that the
+// certificate is within its validity period
+func CertificateProbe(services []string, certFile, keyFile string) Probe {
+	return Probe{
+		Name:     "certificate " + certFile,
+		Services: services,
+		Check: func(ctx context.Context) error {
+			pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return err
+			}
+			if len(pair.Certificate) == 0 {
+				return errors.New("no certificate found")
+			}
+			cert, err := x509.ParseCertificate(pair.Certificate[0])
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+				return fmt.Errorf("certificate is only valid from %s to %s", cert.NotBefore, cert.NotAfter)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package healthcheck_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/healthcheck"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	books      = "book.BookSearchAPI"
+	calculator = "calculator.CalculatorAPI"
+	serving    = healthpb.HealthCheckResponse_SERVING
+	notServing = healthpb.HealthCheckResponse_NOT_SERVING
+)
+
+// dependency is a probe target failing while err is set
+type dependency struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (d *dependency) set(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+func (d *dependency) check(context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+// serve registers the health service of c on an in-process server and
+// returns a client of it
+func serve(t *testing.T, c *healthcheck.Checker) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	c.Register(s)
+	go s.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+	return healthpb.NewHealthClient(conn)
+}
+
+// expect checks the status of each service, "" being the overall status
+func expect(t *testing.T, client healthpb.HealthClient, step string, want map[string]healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	for svc, st := range want {
+		res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: svc})
+		if err != nil {
+			t.Fatalf("%s: Check(%q): %v", step, svc, err)
+		}
+		if res.GetStatus() != st {
+			t.Errorf("%s: %q is %v, want %v", step, svc, res.GetStatus(), st)
+		}
+	}
+}
+
+func TestStatusFollowsProbes(t *testing.T) {
+	store := &dependency{}
+	c := healthcheck.New([]string{books, calculator}, time.Second, healthcheck.Probe{
+		Name:     "book store",
+		Services: []string{books},
+		Check:    store.check,
+	})
+	client := serve(t, c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: books})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := func(step string, want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		res, err := watch.Recv()
+		if err != nil {
+			t.Fatalf("%s: Watch: %v", step, err)
+		}
+		if res.GetStatus() != want {
+			t.Errorf("%s: Watch got %v, want %v", step, res.GetStatus(), want)
+		}
+	}
+
+	expect(t, client, "before the first probe", map[string]healthpb.HealthCheckResponse_ServingStatus{books: notServing, calculator: notServing, "": notServing})
+	watched("before the first probe", notServing)
+
+	for _, step := range []struct {
+		name string
+		err  error
+		want healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{"dependency available", nil, serving},
+		{"dependency unavailable", errors.New("store unreachable"), notServing},
+		{"dependency recovered", nil, serving},
+	} {
+		store.set(step.err)
+		c.CheckNow(context.Background())
+		expect(t, client, step.name, map[string]healthpb.HealthCheckResponse_ServingStatus{books: step.want, calculator: serving, "": step.want})
+		watched(step.name, step.want)
+	}
+
+	// an unchanged status is not sent again
+	c.CheckNow(context.Background())
+	store.set(errors.New("store unreachable"))
+	c.CheckNow(context.Background())
+	watched("unavailable again", notServing)
+}
+
+// writeCert writes a self-signed key pair valid until notAfter
+func writeCert(t *testing.T, certFile, keyFile string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    notAfter.Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailureThreshold(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.pem")
+	probe := healthcheck.CertificateProbe([]string{books}, certFile, keyFile)
+	probe.FailureThreshold = 3
+	c := healthcheck.New([]string{books}, time.Second, probe)
+	client := serve(t, c)
+
+	valid := func() { writeCert(t, certFile, keyFile, time.Now().Add(24*time.Hour)) }
+	expired := func() { writeCert(t, certFile, keyFile, time.Now().Add(-time.Hour)) }
+	missing := func() { os.Remove(certFile) }
+	for _, step := range []struct {
+		name   string
+		change func()
+		want   healthpb.HealthCheckResponse_ServingStatus
+	}{
+		// a certificate that never worked is not given the benefit of the doubt
+		{"missing at startup", missing, notServing},
+		{"valid", valid, serving},
+		{"expired once", expired, serving},
+		{"expired twice", func() {}, serving},
+		{"expired three times", func() {}, notServing},
+		{"expired four times", func() {}, notServing},
+		{"renewed", valid, serving},
+		// the failures in a row start over after a success
+		{"missing once", missing, serving},
+		{"missing twice", func() {}, serving},
+		{"restored", valid, serving},
+		{"missing once more", missing, serving},
+		{"missing twice more", func() {}, serving},
+		{"missing three times", func() {}, notServing},
+	} {
+		step.change()
+		c.CheckNow(context.Background())
+		expect(t, client, step.name, map[string]healthpb.HealthCheckResponse_ServingStatus{books: step.want, "": step.want})
+	}
+}
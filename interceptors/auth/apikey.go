@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIKeyStore maps static API keys to caller identities.
+// Keys are indexed by their SHA-256 digest so the plain values need not be
+// kept on disk.
+type APIKeyStore struct {
+	keys map[string]*Identity
+}
+
+// apiKeyEntry is one entry of the API keys file, e.g.
+//
+//	keys:
+//	  - name: reporting-partner
+//	    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    roles: [reader]
+//	  - name: ops
+//	    key: local-development-key
+//	    roles: [admin]
+type apiKeyEntry struct {
+	Name   string   `yaml:"name"`
+	Key    string   `yaml:"key"`
+	SHA256 string   `yaml:"sha256"`
+	Roles  []string `yaml:"roles"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// LoadAPIKeyStore reads an API keys file from disk
+func LoadAPIKeyStore(path string) (*APIKeyStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Keys []apiKeyEntry `yaml:"keys"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing API keys file: %w", err)
+	}
+	store := &APIKeyStore{keys: make(map[string]*Identity, len(doc.Keys))}
+	for i, e := range doc.Keys {
+		if e.Name == "" {
+			return nil, fmt.Errorf("API key %d has no name", i)
+		}
+		digest := strings.ToLower(e.SHA256)
+		switch {
+		case e.Key != "" && digest != "":
+			return nil, fmt.Errorf("API key %q sets both key and sha256", e.Name)
+		case e.Key != "":
+			digest = hashKey(e.Key)
+		case len(digest) != sha256.Size*2:
+			return nil, fmt.Errorf("API key %q needs a key or a hex encoded sha256", e.Name)
+		}
+		store.keys[digest] = &Identity{
+			Subject: e.Name,
+			Roles:   e.Roles,
+			Scopes:  e.Scopes,
+			Method:  "api-key",
+		}
+	}
+	return store, nil
+}
+
+// Lookup returns the identity owning key
+func (s *APIKeyStore) Lookup(key string) (*Identity, bool) {
+	id, ok := s.keys[hashKey(key)]
+	return id, ok
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
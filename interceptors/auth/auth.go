@@ -0,0 +1,173 @@
+// Package auth provides gRPC server interceptors that authenticate callers
+// using bearer JWTs or static API keys, and the matching client side
+// per-RPC credentials.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// AuthorizationHeader carries a "Bearer <jwt>" token
+	AuthorizationHeader = "authorization"
+	// APIKeyHeader carries a static API key
+	APIKeyHeader = "x-api-key"
+
+	// ReasonUnauthenticated is the ErrorInfo reason of the calls rejected
+	// for missing or invalid credentials
+	ReasonUnauthenticated = "UNAUTHENTICATED"
+
+	bearerPrefix = "bearer "
+)
+
+// Identity describes an authenticated caller
+type Identity struct {
+	// Subject is the JWT "sub" claim or the name configured for an API key
+	Subject string
+	// Roles granted to the caller
+	Roles []string
+	// Scopes granted to the caller
+	Scopes []string
+	// Method is the mechanism used to authenticate: "jwt" or "api-key"
+	Method string
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying the caller identity
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the caller identity attached by the interceptors, if any
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// Authenticator validates the credentials presented in the incoming metadata
+type Authenticator struct {
+	jwt     *JWTVerifier
+	apiKeys *APIKeyStore
+	exempt  []string
+}
+
+// NewAuthenticator returns an Authenticator accepting bearer tokens verified
+// by jwt and API keys found in apiKeys. Either of them may be nil to disable
+// that mechanism.
+func NewAuthenticator(jwt *JWTVerifier, apiKeys *APIKeyStore) *Authenticator {
+	return &Authenticator{jwt: jwt, apiKeys: apiKeys}
+}
+
+// LoadAuthenticator builds an Authenticator from a JWKS file and an API keys
+// file. An empty path disables the corresponding mechanism.
+func LoadAuthenticator(jwksFile, apiKeysFile string, opts ...JWTOption) (*Authenticator, error) {
+	a := &Authenticator{}
+	if jwksFile != "" {
+		keys, err := LoadKeySet(jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = NewJWTVerifier(keys, opts...)
+	}
+	if apiKeysFile != "" {
+		store, err := LoadAPIKeyStore(apiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		a.apiKeys = store
+	}
+	return a, nil
+}
+
+// Exempt lets anonymous callers reach the methods starting with any of the
+// prefixes, e.g. "/grpc.health.v1.Health/"
+func (a *Authenticator) Exempt(prefixes ...string) {
+	a.exempt = append(a.exempt, prefixes...)
+}
+
+func (a *Authenticator) isExempt(method string) bool {
+	for _, prefix := range a.exempt {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate inspects the incoming metadata and returns the caller identity.
+// Failures are reported as codes.Unauthenticated errors with the
+// ReasonUnauthenticated reason.
+func (a *Authenticator) Authenticate(ctx context.Context) (*Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(AuthorizationHeader); len(values) > 0 && a.jwt != nil {
+		value := values[0]
+		if len(value) < len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+			return nil, unauthenticated("authorization header must use the Bearer scheme")
+		}
+		id, err := a.jwt.Verify(strings.TrimSpace(value[len(bearerPrefix):]))
+		if err != nil {
+			return nil, unauthenticated(fmt.Sprintf("invalid bearer token: %v", err))
+		}
+		return id, nil
+	}
+	if values := md.Get(APIKeyHeader); len(values) > 0 && a.apiKeys != nil {
+		id, ok := a.apiKeys.Lookup(values[0])
+		if !ok {
+			return nil, unauthenticated("invalid API key")
+		}
+		return id, nil
+	}
+	return nil, unauthenticated("missing credentials")
+}
+
+func unauthenticated(msg string) error {
+	return apierror.ServerDomain.Error(codes.Unauthenticated, ReasonUnauthenticated, msg, nil)
+}
+
+// UnaryServerInterceptor authenticates every unary call and attaches the
+// caller identity to the handler context
+func UnaryServerInterceptor(a *Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if a.isExempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		id, err := a.Authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(NewContext(ctx, id), req)
+	}
+}
+
+// StreamServerInterceptor authenticates every streaming call and attaches the
+// caller identity to the stream context
+func StreamServerInterceptor(a *Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if a.isExempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		id, err := a.Authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: NewContext(ss.Context(), id)})
+	}
+}
+
+// serverStream overrides the context of the wrapped stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
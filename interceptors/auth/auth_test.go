@@ -0,0 +1,144 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var hsSecret = []byte("auth-test-secret")
+
+// keySet returns a JWKS holding the HS256 key "hs" and the RS256 key "rs"
+func keySet(t *testing.T) (*auth.KeySet, *rsa.PrivateKey) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	doc := fmt.Sprintf(`{"keys": [
+		{"kty": "oct", "kid": "hs", "alg": "HS256", "k": %q},
+		{"kty": "RSA", "kid": "rs", "alg": "RS256", "n": %q, "e": %q}
+	]}`, enc(hsSecret), enc(rsaKey.N.Bytes()), enc(big.NewInt(int64(rsaKey.E)).Bytes()))
+	keys, err := auth.ParseKeySet([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys, rsaKey
+}
+
+// apiKeys returns a store holding the SHA-256 digest of "s3cret" only
+func apiKeys(t *testing.T) *auth.APIKeyStore {
+	t.Helper()
+	sum := sha256.Sum256([]byte("s3cret"))
+	path := filepath.Join(t.TempDir(), "api-keys.yaml")
+	doc := fmt.Sprintf("keys:\n  - name: partner\n    sha256: %s\n    roles: [reader]\n", hex.EncodeToString(sum[:]))
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := auth.LoadAPIKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys, rsaKey := keySet(t)
+	a := auth.NewAuthenticator(auth.NewJWTVerifier(keys), apiKeys(t))
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := jwt.MapClaims{"sub": "alice", "exp": exp, "roles": []string{"reader"}}
+
+	for _, tc := range []struct {
+		name        string
+		md          metadata.MD
+		wantSubject string
+	}{
+		{"HS256 token", metadata.Pairs(auth.AuthorizationHeader, "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", hsSecret, valid)), "alice"},
+		{"RS256 token", metadata.Pairs(auth.AuthorizationHeader, "bearer "+sign(t, jwt.SigningMethodRS256, "rs", rsaKey, valid)), "alice"},
+		{"HS256 token naming the RS256 key", metadata.Pairs(auth.AuthorizationHeader, "Bearer "+sign(t, jwt.SigningMethodHS256, "rs", hsSecret, valid)), ""},
+		{"unknown key id", metadata.Pairs(auth.AuthorizationHeader, "Bearer "+sign(t, jwt.SigningMethodHS256, "other", hsSecret, valid)), ""},
+		{"wrong secret", metadata.Pairs(auth.AuthorizationHeader, "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", []byte("guess"), valid)), ""},
+		{"expired token", metadata.Pairs(auth.AuthorizationHeader, "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", hsSecret, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Minute).Unix()})), ""},
+		{"no exp", metadata.Pairs(auth.AuthorizationHeader, "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", hsSecret, jwt.MapClaims{"sub": "alice"})), ""},
+		{"no subject", metadata.Pairs(auth.AuthorizationHeader, "Bearer "+sign(t, jwt.SigningMethodHS256, "hs", hsSecret, jwt.MapClaims{"exp": exp})), ""},
+		{"basic scheme", metadata.Pairs(auth.AuthorizationHeader, "Basic YWxpY2U6c2VjcmV0"), ""},
+		{"bearer without token", metadata.Pairs(auth.AuthorizationHeader, "Bearer"), ""},
+		{"malformed token", metadata.Pairs(auth.AuthorizationHeader, "Bearer not.a.jwt"), ""},
+		{"valid API key", metadata.Pairs(auth.APIKeyHeader, "s3cret"), "partner"},
+		{"wrong API key", metadata.Pairs(auth.APIKeyHeader, "s3cret "), ""},
+		{"no credentials", nil, ""},
+	} {
+		id, err := a.Authenticate(metadata.NewIncomingContext(context.Background(), tc.md))
+		if tc.wantSubject != "" {
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			} else if id.Subject != tc.wantSubject {
+				t.Errorf("%s: subject %q, want %q", tc.name, id.Subject, tc.wantSubject)
+			}
+			continue
+		}
+		if status.Code(err) != codes.Unauthenticated || apierror.Reason(err) != auth.ReasonUnauthenticated {
+			t.Errorf("%s: got %v, want Unauthenticated with reason %s", tc.name, err, auth.ReasonUnauthenticated)
+		}
+	}
+}
+
+func TestExempt(t *testing.T) {
+	a := auth.NewAuthenticator(nil, apiKeys(t))
+	a.Exempt("/grpc.health.v1.Health/")
+	intercept := auth.UnaryServerInterceptor(a)
+	for _, tc := range []struct {
+		method   string
+		md       metadata.MD
+		wantCode codes.Code
+	}{
+		{"/grpc.health.v1.Health/Check", nil, codes.OK},
+		{"/book.BookSearchAPI/GetBook", nil, codes.Unauthenticated},
+		{"/book.BookSearchAPI/GetBook", metadata.Pairs(auth.APIKeyHeader, "s3cret"), codes.OK},
+		// the prefix must match from the start of the method
+		{"/evil.Service/grpc.health.v1.Health/Check", nil, codes.Unauthenticated},
+	} {
+		var identified bool
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			_, identified = auth.FromContext(ctx)
+			return nil, nil
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+		_, err := intercept(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+		if status.Code(err) != tc.wantCode {
+			t.Errorf("%s with %v: got %v, want %v", tc.method, tc.md, err, tc.wantCode)
+		}
+		if want := tc.md != nil; err == nil && identified != want {
+			t.Errorf("%s with %v: identity attached = %v, want %v", tc.method, tc.md, identified, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// staticCredentials attaches a fixed header to every RPC
+type staticCredentials struct {
+	header     string
+	value      string
+	requireTLS bool
+}
+
+// NewBearerCredentials returns per-RPC credentials sending token as a bearer
+// JWT. When requireTLS is set gRPC refuses to send the token over an insecure
+// connection.
+func NewBearerCredentials(token string, requireTLS bool) credentials.PerRPCCredentials {
+	return staticCredentials{header: AuthorizationHeader, value: "Bearer " + token, requireTLS: requireTLS}
+}
+
+// NewAPIKeyCredentials returns per-RPC credentials sending a static API key
+func NewAPIKeyCredentials(key string, requireTLS bool) credentials.PerRPCCredentials {
+	return staticCredentials{header: APIKeyHeader, value: key, requireTLS: requireTLS}
+}
+
+func (c staticCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{c.header: c.value}, nil
+}
+
+func (c staticCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Key is a verification key together with the only algorithm it may be used with
+type Key struct {
+	ID        string
+	Algorithm string
+	// Key is a []byte for HS256, *rsa.PublicKey for RS256 and
+	// *ecdsa.PublicKey for ES256
+	Key interface{}
+}
+
+// KeySet holds the keys of a JWKS document indexed by key id
+type KeySet struct {
+	keys map[string]Key
+}
+
+// jwk is the JSON Web Key representation defined by RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// oct
+	K string `json:"k"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadKeySet reads a JWKS file from disk
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeySet(data)
+}
+
+// ParseKeySet parses a JWKS document. Only "sig" keys of type oct (HS256),
+// RSA (RS256) and EC P-256 (ES256) are accepted.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %w", err)
+	}
+	set := &KeySet{keys: make(map[string]Key, len(doc.Keys))}
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.parse()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d (%q): %w", i, k.Kid, err)
+		}
+		if _, dup := set.keys[key.ID]; dup {
+			return nil, fmt.Errorf("JWKS key id %q is used more than once", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+	return set, nil
+}
+
+// Get returns the key with the given id
+func (s *KeySet) Get(kid string) (Key, bool) {
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+func (k jwk) parse() (Key, error) {
+	key := Key{ID: k.Kid}
+	switch k.Kty {
+	case "oct":
+		secret, err := decodeSegment(k.K)
+		if err != nil {
+			return key, fmt.Errorf("invalid k: %w", err)
+		}
+		key.Algorithm, key.Key = "HS256", secret
+	case "RSA":
+		n, err := decodeSegment(k.N)
+		if err != nil {
+			return key, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeSegment(k.E)
+		if err != nil {
+			return key, fmt.Errorf("invalid e: %w", err)
+		}
+		key.Algorithm = "RS256"
+		key.Key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		if k.Crv != "P-256" {
+			return key, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return key, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeSegment(k.Y)
+		if err != nil {
+			return key, fmt.Errorf("invalid y: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return key, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		key.Algorithm, key.Key = "ES256", pub
+	default:
+		return key, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	if k.Alg != "" && k.Alg != key.Algorithm {
+		return key, fmt.Errorf("algorithm %q does not match key type %q", k.Alg, k.Kty)
+	}
+	return key, nil
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claims are the JWT claims understood by the verifier
+type claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	// Scope is a space separated list, as defined by RFC 8693
+	Scope string `json:"scope,omitempty"`
+}
+
+// JWTVerifier validates HS256, RS256 and ES256 signed tokens against the keys
+// of a local JWKS file
+type JWTVerifier struct {
+	keys   *KeySet
+	parser *jwt.Parser
+}
+
+// JWTOption configures a JWTVerifier
+type JWTOption func(*[]jwt.ParserOption)
+
+// WithIssuer requires the "iss" claim to match issuer
+func WithIssuer(issuer string) JWTOption {
+	return func(opts *[]jwt.ParserOption) {
+		*opts = append(*opts, jwt.WithIssuer(issuer))
+	}
+}
+
+// WithAudience requires the "aud" claim to contain audience
+func WithAudience(audience string) JWTOption {
+	return func(opts *[]jwt.ParserOption) {
+		*opts = append(*opts, jwt.WithAudience(audience))
+	}
+}
+
+// WithLeeway tolerates clock skew when checking "exp" and "nbf"
+func WithLeeway(leeway time.Duration) JWTOption {
+	return func(opts *[]jwt.ParserOption) {
+		*opts = append(*opts, jwt.WithLeeway(leeway))
+	}
+}
+
+// NewJWTVerifier returns a verifier using the given key set
+func NewJWTVerifier(keys *KeySet, opts ...JWTOption) *JWTVerifier {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256", "ES256"}),
+		jwt.WithExpirationRequired(),
+	}
+	for _, opt := range opts {
+		opt(&parserOpts)
+	}
+	return &JWTVerifier{keys: keys, parser: jwt.NewParser(parserOpts...)}
+}
+
+// Verify checks the signature and claims of token and returns the identity
+// it describes
+func (v *JWTVerifier) Verify(token string) (*Identity, error) {
+	var c claims
+	_, err := v.parser.ParseWithClaims(token, &c, v.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if c.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &Identity{
+		Subject: c.Subject,
+		Roles:   c.Roles,
+		Scopes:  strings.Fields(c.Scope),
+		Method:  "jwt",
+	}, nil
+}
+
+// keyFunc picks the verification key named by the "kid" header and makes
+// sure it matches the signing algorithm of the token
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := v.keys.Get(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if key.Algorithm != token.Method.Alg() {
+		return nil, fmt.Errorf("key %q cannot be used with %s", kid, token.Method.Alg())
+	}
+	return key.Key, nil
+}
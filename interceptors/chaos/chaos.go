@@ -0,0 +1,331 @@
+// Package chaos injects faults into the calls of a server, so the resilience
+// of its clients can be tested against a flaky service: added latency,
+// errors instead of responses, streams aborted halfway and stream messages
+// dropped, for a percentage of the calls of chosen methods.
+//
+// Injection is disabled until the configuration enables it, from a YAML file
+// at startup or at runtime through AdminAPI.SetChaos.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// Config lists the faults injected
+type Config struct {
+	// Enabled turns the injection on, no fault is injected while false
+	Enabled bool `yaml:"enabled"`
+	// Rules are tried in order, a call follows the first one matching its
+	// method
+	Rules []Rule `yaml:"rules"`
+}
+
+// MaxLatency bounds the latency plus jitter of a rule, so a mistyped rule
+// cannot stall the calls for hours
+const MaxLatency = 30 * time.Second
+
+// Rule describes the faults injected into the calls of some methods
+type Rule struct {
+	// Methods lists fully qualified methods, or services with a trailing
+	// slash (e.g. "/book.BookSearchAPI/"). The rule covers every method when
+	// empty.
+	Methods []string `yaml:"methods"`
+	// Percentage of the matching calls affected, from 0 to 100
+	Percentage float64 `yaml:"percentage"`
+	// Latency is added before the handler runs
+	Latency time.Duration `yaml:"latency"`
+	// Jitter is a random latency added on top of Latency, up to this;
+	// together at most MaxLatency
+	Jitter time.Duration `yaml:"jitter"`
+	// Code is the canonical status code returned instead of running the
+	// handler (e.g. "UNAVAILABLE"), the handler runs when empty
+	Code string `yaml:"code"`
+	// Message is the message of the status returned
+	Message string `yaml:"message"`
+	// AbortAfterMessages aborts streams once this many messages were sent
+	// or received, 0 for no abort
+	AbortAfterMessages int `yaml:"abort_after_messages"`
+	// AbortCode is the canonical status code of the aborted streams,
+	// UNAVAILABLE when empty
+	AbortCode string `yaml:"abort_code"`
+	// DropPercentage is the percentage of the stream messages dropped, in
+	// the streaming directions
+	DropPercentage float64 `yaml:"drop_percentage"`
+}
+
+// LoadConfig reads a YAML chaos file from disk
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing chaos config: %w", err)
+	}
+	if _, err := compile(*cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Injector injects the faults of its configuration, which can be replaced
+// while the server runs
+type Injector struct {
+	state  atomic.Pointer[state]
+	exempt []string
+}
+
+// state is a validated configuration
+type state struct {
+	cfg   Config
+	rules []rule
+}
+
+type rule struct {
+	Rule
+	code      codes.Code
+	abortCode codes.Code
+}
+
+// New returns an Injector, disabled
+func New() *Injector {
+	i := &Injector{}
+	i.state.Store(&state{})
+	return i
+}
+
+// Exempt keeps the methods starting with any of the prefixes out of reach,
+// e.g. the service used to turn the injection off
+func (i *Injector) Exempt(prefixes ...string) {
+	i.exempt = append(i.exempt, prefixes...)
+}
+
+// Config returns the current configuration
+func (i *Injector) Config() Config {
+	return i.state.Load().cfg
+}
+
+// SetConfig replaces the configuration. The calls already running keep the
+// faults they were given.
+func (i *Injector) SetConfig(cfg Config) error {
+	s, err := compile(cfg)
+	if err != nil {
+		return err
+	}
+	i.state.Store(s)
+	return nil
+}
+
+func compile(cfg Config) (*state, error) {
+	s := &state{cfg: cfg}
+	for n, r := range cfg.Rules {
+		if r.Percentage < 0 || r.Percentage > 100 || r.DropPercentage < 0 || r.DropPercentage > 100 {
+			return nil, fmt.Errorf("rule %d: percentages must be between 0 and 100", n)
+		}
+		if r.Latency < 0 || r.Jitter < 0 || r.AbortAfterMessages < 0 {
+			return nil, fmt.Errorf("rule %d: latency, jitter and abort_after_messages must not be negative", n)
+		}
+		if r.Latency+r.Jitter > MaxLatency {
+			return nil, fmt.Errorf("rule %d: latency plus jitter must not exceed %v", n, MaxLatency)
+		}
+		c := rule{Rule: r, code: codes.OK, abortCode: codes.Unavailable}
+		var err error
+		if r.Code != "" {
+			if c.code, err = parseCode(r.Code); err != nil {
+				return nil, fmt.Errorf("rule %d: %w", n, err)
+			}
+		}
+		if r.AbortCode != "" {
+			if c.abortCode, err = parseCode(r.AbortCode); err != nil {
+				return nil, fmt.Errorf("rule %d: %w", n, err)
+			}
+			if c.abortCode == codes.OK {
+				return nil, fmt.Errorf("rule %d: abort_code must not be OK", n)
+			}
+		}
+		s.rules = append(s.rules, c)
+	}
+	return s, nil
+}
+
+// parseCode parses a canonical code name, e.g. UNAVAILABLE
+func parseCode(name string) (codes.Code, error) {
+	var c codes.Code
+	if err := c.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(name)))); err != nil {
+		return 0, fmt.Errorf("unknown status code %q", name)
+	}
+	return c, nil
+}
+
+// pick returns the rule affecting a call of fullMethod, nil for none
+func (i *Injector) pick(fullMethod string) *rule {
+	s := i.state.Load()
+	if !s.cfg.Enabled {
+		return nil
+	}
+	for _, prefix := range i.exempt {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return nil
+		}
+	}
+	for n := range s.rules {
+		r := &s.rules[n]
+		if !r.covers(fullMethod) {
+			continue
+		}
+		if rand.Float64()*100 < r.Percentage {
+			return r
+		}
+		return nil
+	}
+	return nil
+}
+
+func (r *rule) covers(fullMethod string) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if m == fullMethod || (strings.HasSuffix(m, "/") && strings.HasPrefix(fullMethod, m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay waits for the latency of the rule, unless the call ends before
+func (r *rule) delay(ctx context.Context) error {
+	d := r.Latency
+	if r.Jitter > 0 {
+		d += rand.N(r.Jitter)
+	}
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// fault returns the error returned instead of running the handler, nil to
+// run it
+func (r *rule) fault() error {
+	if r.code == codes.OK {
+		return nil
+	}
+	msg := r.Message
+	if msg == "" {
+		msg = "chaos: injected fault"
+	}
+	return status.Error(r.code, msg)
+}
+
+// UnaryServerInterceptor injects latency and errors into unary calls
+func (i *Injector) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		r := i.pick(info.FullMethod)
+		if r == nil {
+			return handler(ctx, req)
+		}
+		if err := r.delay(ctx); err != nil {
+			return nil, err
+		}
+		if err := r.fault(); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor injects latency and errors into streaming calls,
+// aborts them and drops their messages
+func (i *Injector) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		r := i.pick(info.FullMethod)
+		if r == nil {
+			return handler(srv, ss)
+		}
+		if err := r.delay(ss.Context()); err != nil {
+			return err
+		}
+		if err := r.fault(); err != nil {
+			return err
+		}
+		cs := &chaosStream{ServerStream: ss, r: r, dropSent: info.IsServerStream, dropReceived: info.IsClientStream}
+		err := handler(srv, cs)
+		if cs.aborted.Load() {
+			// whatever the handler made of the error of SendMsg or RecvMsg
+			return cs.abortErr()
+		}
+		return err
+	}
+}
+
+// chaosStream drops and counts the messages of a stream, and aborts it once
+// the rule says so. The single message of the unary side, if any, is never
+// dropped.
+type chaosStream struct {
+	grpc.ServerStream
+	r                      *rule
+	dropSent, dropReceived bool
+	messages               atomic.Int64
+	aborted                atomic.Bool
+}
+
+func (s *chaosStream) abortErr() error {
+	return status.Errorf(s.r.abortCode, "chaos: stream aborted after %d messages", s.r.AbortAfterMessages)
+}
+
+// count counts a message and reports whether the stream must be aborted
+func (s *chaosStream) count() bool {
+	n := s.messages.Add(1)
+	if s.r.AbortAfterMessages > 0 && n > int64(s.r.AbortAfterMessages) {
+		s.aborted.Store(true)
+	}
+	return s.aborted.Load()
+}
+
+func (s *chaosStream) drop(streaming bool) bool {
+	return streaming && s.r.DropPercentage > 0 && rand.Float64()*100 < s.r.DropPercentage
+}
+
+func (s *chaosStream) SendMsg(m interface{}) error {
+	if s.count() {
+		return s.abortErr()
+	}
+	if s.drop(s.dropSent) {
+		return nil
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *chaosStream) RecvMsg(m interface{}) error {
+	for {
+		err := s.ServerStream.RecvMsg(m)
+		if err != nil {
+			return err
+		}
+		if s.count() {
+			return s.abortErr()
+		}
+		if !s.drop(s.dropReceived) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package chaos_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/chaos"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func start(t *testing.T, injector *chaos.Injector) *testharness.Harness {
+	return testharness.Start(t, testharness.Options{
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{injector.UnaryServerInterceptor()},
+		StreamInterceptors: []grpc.StreamServerInterceptor{injector.StreamServerInterceptor()},
+	})
+}
+
+func TestUnaryFaults(t *testing.T) {
+	injector := chaos.New()
+	injector.Exempt("/calculator.CalculatorAPI/Sum")
+	h := start(t, injector)
+	ctx := context.Background()
+	getBook := func() error {
+		_, err := h.Books.GetBook(ctx, &bookpb.GetBookRequest{Title: "Java"})
+		return err
+	}
+
+	rules := []chaos.Rule{{Methods: []string{"/book.BookSearchAPI/GetBook", "/calculator.CalculatorAPI/"}, Percentage: 100, Code: "unavailable"}}
+	if err := injector.SetConfig(chaos.Config{Rules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	if err := getBook(); err != nil {
+		t.Fatalf("GetBook with the injection disabled: %v", err)
+	}
+
+	if err := injector.SetConfig(chaos.Config{Enabled: true, Rules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	if err := getBook(); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetBook: got %v, want Unavailable", err)
+	}
+	if _, err := h.Calculator.Divide(ctx, &computepb.DivideRequest{Dividend: 1, Divisor: 1}); status.Code(err) != codes.Unavailable {
+		t.Errorf("Divide: got %v, want Unavailable", err)
+	}
+	if _, err := h.Calculator.Sum(ctx, &computepb.SumRequest{Number1: 1, Number2: 2}); err != nil {
+		t.Errorf("exempt Sum: %v", err)
+	}
+
+	rules = []chaos.Rule{{Methods: []string{"/book.BookSearchAPI/GetBook"}, Percentage: 100, Latency: 50 * time.Millisecond}}
+	if err := injector.SetConfig(chaos.Config{Enabled: true, Rules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	begin := time.Now()
+	if err := getBook(); err != nil {
+		t.Fatalf("GetBook with latency: %v", err)
+	}
+	if d := time.Since(begin); d < 50*time.Millisecond {
+		t.Errorf("GetBook took %v, want at least 50ms", d)
+	}
+}
+
+func TestStreamFaults(t *testing.T) {
+	injector := chaos.New()
+	h := start(t, injector)
+	rules := []chaos.Rule{{Methods: []string{"/book.BookSearchAPI/GetEachBook"}, Percentage: 100, AbortAfterMessages: 3, AbortCode: "ABORTED"}}
+	if err := injector.SetConfig(chaos.Config{Enabled: true, Rules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := h.Books.GetEachBook(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one title and its book count two messages, the next title aborts
+	for i := 0; i < 2; i++ {
+		if err := stream.Send(&bookpb.GetEachBookRequest{Title: "Java"}); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("first book: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Aborted {
+		t.Errorf("second book: got %v, want Aborted", err)
+	}
+
+	// every message dropped: the books never come back
+	rules = []chaos.Rule{{Methods: []string{"/book.BookSearchAPI/GetAllBooks"}, Percentage: 100, DropPercentage: 100}}
+	if err := injector.SetConfig(chaos.Config{Enabled: true, Rules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	all, err := h.Books.GetAllBooks(context.Background(), &bookpb.GetAllBooksRequest{Title: "Java"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := all.Recv(); err != io.EOF {
+		t.Errorf("GetAllBooks: got %v, want io.EOF", err)
+	}
+}
+
+func TestSetConfigValidates(t *testing.T) {
+	for name, r := range map[string]chaos.Rule{
+		"percentage":      {Percentage: 101},
+		"drop percentage": {DropPercentage: -1},
+		"latency":         {Latency: -time.Second},
+		"too slow":        {Latency: 20 * time.Second, Jitter: 20 * time.Second},
+		"code":            {Code: "NOT_A_CODE"},
+		"abort code":      {AbortCode: "OK"},
+	} {
+		injector := chaos.New()
+		if err := injector.SetConfig(chaos.Config{Enabled: true, Rules: []chaos.Rule{r}}); err == nil {
+			t.Errorf("%s: invalid rule accepted", name)
+		}
+		if injector.Config().Enabled {
+			t.Errorf("%s: config replaced by an invalid one", name)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package logging provides gRPC server interceptors writing one structured
+// log record per RPC with log/slog.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"path"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Options configures the logging interceptors
+type Options struct {
+	// Logger receives the records, slog.Default() when nil
+	Logger *slog.Logger
+	// SampleRate is the fraction of successful RPCs that are logged, between
+	// 0 and 1. Failed RPCs are always logged.
+	SampleRate float64
+	// LogPayloads adds the unary request and response messages to the record
+	LogPayloads bool
+	// Redact lists the proto field names whose values are masked when
+	// payloads are logged, at any nesting level (e.g. "title", "author").
+	// Error messages may quote the requests, so when it is set the failures
+	// are logged with their code and ErrorInfo reason only.
+	Redact []string
+}
+
+// Redacted replaces the value of redacted string fields
+const Redacted = "[REDACTED]"
+
+type logger struct {
+	Options
+	redact map[protoreflect.Name]bool
+}
+
+func newLogger(opts Options) *logger {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	l := &logger{Options: opts, redact: make(map[protoreflect.Name]bool, len(opts.Redact))}
+	for _, name := range opts.Redact {
+		l.redact[protoreflect.Name(name)] = true
+	}
+	return l
+}
+
+// UnaryServerInterceptor logs every unary call
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	l := newLogger(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if !l.sampled(err) {
+			return resp, err
+		}
+		attrs := []slog.Attr{
+			slog.Int("request_size", size(req)),
+			slog.Int("response_size", size(resp)),
+		}
+		if l.LogPayloads {
+			attrs = append(attrs, slog.Any("request", l.payload(req)))
+			if err == nil {
+				attrs = append(attrs, slog.Any("response", l.payload(resp)))
+			}
+		}
+		l.log(ctx, "unary", info.FullMethod, start, err, attrs...)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs every streaming call once it completes,
+// with the number and total size of the messages exchanged
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	l := newLogger(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		counted := &countingStream{ServerStream: ss}
+		err := handler(srv, counted)
+		if !l.sampled(err) {
+			return err
+		}
+		l.log(ss.Context(), streamType(info), info.FullMethod, start, err,
+			slog.Int("messages_received", counted.received),
+			slog.Int("messages_sent", counted.sent),
+			slog.Int("request_size", counted.receivedBytes),
+			slog.Int("response_size", counted.sentBytes),
+		)
+		return err
+	}
+}
+
+func (l *logger) sampled(err error) bool {
+	return err != nil || l.SampleRate >= 1 || rand.Float64() < l.SampleRate
+}
+
+func (l *logger) log(ctx context.Context, kind, fullMethod string, start time.Time, err error, extra ...slog.Attr) {
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("grpc.service", path.Dir(fullMethod)[1:]),
+		slog.String("grpc.method", path.Base(fullMethod)),
+		slog.String("grpc.type", kind),
+		slog.String("grpc.code", code.String()),
+		slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	if err != nil {
+		msg := status.Convert(err).Message()
+		if len(l.redact) > 0 {
+			msg = Redacted
+		}
+		attrs = append(attrs, slog.String("error", msg))
+		if reason := apierror.Reason(err); reason != "" {
+			attrs = append(attrs, slog.String("error_reason", reason))
+		}
+	}
+	attrs = append(attrs, extra...)
+	l.Logger.LogAttrs(ctx, level(code), "finished call", attrs...)
+}
+
+// payload renders msg as JSON with the redacted fields masked
+func (l *logger) payload(msg interface{}) interface{} {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return nil
+	}
+	if len(l.redact) > 0 {
+		m = proto.Clone(m)
+		l.mask(m.ProtoReflect())
+	}
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return err.Error()
+	}
+	return rawJSON(data)
+}
+
+// mask clears the redacted fields of m, replacing strings by Redacted so the
+// reader can tell the field was set
+func (l *logger) mask(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case l.redact[fd.Name()]:
+			if fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+				m.Set(fd, protoreflect.ValueOfString(Redacted))
+			} else {
+				m.Clear(fd)
+			}
+		case fd.Message() != nil && fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				l.mask(list.Get(i).Message())
+			}
+		case fd.Message() != nil && !fd.IsMap():
+			l.mask(v.Message())
+		}
+		return true
+	})
+}
+
+// rawJSON lets slog's JSON handler embed an already encoded document
+type rawJSON []byte
+
+func (r rawJSON) MarshalJSON() ([]byte, error) {
+	return r, nil
+}
+
+// level maps server side failures to Error, client side ones to Warn
+func level(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
+
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	default:
+		return "server_stream"
+	}
+}
+
+func size(msg interface{}) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return 0
+}
+
+// countingStream counts the messages going through a stream
+type countingStream struct {
+	grpc.ServerStream
+	sent, received           int
+	sentBytes, receivedBytes int
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		s.sentBytes += size(m)
+	}
+	return err
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		s.receivedBytes += size(m)
+	}
+	return err
+}
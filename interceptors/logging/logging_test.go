@@ -0,0 +1,244 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookerrors"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/logging"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const getBook = "/book.BookSearchAPI/GetBook"
+
+// records collects the log records as decoded JSON objects
+type records struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *records) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+func (r *records) logger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(r, nil))
+}
+
+func (r *records) all(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(r.buf.Bytes()))
+	for {
+		var rec map[string]interface{}
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			return all
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, rec)
+	}
+}
+
+func (r *records) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.String()
+}
+
+// call runs intercept on req with a handler answering res and err
+func call(intercept grpc.UnaryServerInterceptor, req, res interface{}, err error) {
+	intercept(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: getBook}, func(context.Context, interface{}) (interface{}, error) {
+		return res, err
+	})
+}
+
+// field returns the value of the attribute path of rec, or at the dotted path
+// in its payloads, nil if there is none
+func field(rec map[string]interface{}, path string) interface{} {
+	if v, ok := rec[path]; ok {
+		return v
+	}
+	var v interface{} = rec
+	for _, key := range strings.Split(path, ".") {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			v = x[key]
+		case []interface{}:
+			if len(x) == 0 {
+				return nil
+			}
+			v = x[0].(map[string]interface{})[key]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func TestRedaction(t *testing.T) {
+	book := &bookpb.Book{Title: "Java", Author: "Joshua Bloch"}
+	for _, tc := range []struct {
+		name   string
+		redact []string
+		req    interface{}
+		res    interface{}
+		err    error
+		want   map[string]interface{}
+	}{
+		{"nested message", []string{"title"}, &bookpb.GetBookRequest{Title: "Java"}, &bookpb.GetBookResponse{Book: book}, nil, map[string]interface{}{
+			"request.title":        logging.Redacted,
+			"response.book.title":  logging.Redacted,
+			"response.book.author": "Joshua Bloch",
+		}},
+		{"repeated messages", []string{"author"}, &bookpb.GetBookRequest{Title: "Java"}, &bookpb.GetBooksForGivenTitlesResponse{Book: []*bookpb.Book{book, book}}, nil, map[string]interface{}{
+			"request.title":        "Java",
+			"response.book.title":  "Java",
+			"response.book.author": logging.Redacted,
+		}},
+		{"no redaction", nil, &bookpb.GetBookRequest{Title: "Java"}, &bookpb.GetBookResponse{Book: book}, nil, map[string]interface{}{
+			"request.title":       "Java",
+			"response.book.title": "Java",
+		}},
+		// the message of BookNotFound quotes the title
+		{"error message", []string{"title"}, &bookpb.GetBookRequest{Title: "Secret Plans"}, nil, bookerrors.BookNotFound("Secret Plans"), map[string]interface{}{
+			"grpc.code":     codes.NotFound.String(),
+			"error":         logging.Redacted,
+			"error_reason":  bookerrors.ReasonBookNotFound,
+			"request.title": logging.Redacted,
+		}},
+		{"error message without redaction", nil, &bookpb.GetBookRequest{Title: "Secret Plans"}, nil, bookerrors.BookNotFound("Secret Plans"), map[string]interface{}{
+			"error":        status.Convert(bookerrors.BookNotFound("Secret Plans")).Message(),
+			"error_reason": bookerrors.ReasonBookNotFound,
+		}},
+	} {
+		var r records
+		call(logging.UnaryServerInterceptor(logging.Options{Logger: r.logger(), SampleRate: 1, LogPayloads: true, Redact: tc.redact}), tc.req, tc.res, tc.err)
+		all := r.all(t)
+		if len(all) != 1 {
+			t.Fatalf("%s: got %d records, want 1", tc.name, len(all))
+		}
+		for path, want := range tc.want {
+			if got := field(all[0], path); got != want {
+				t.Errorf("%s: %s = %v, want %v", tc.name, path, got, want)
+			}
+		}
+		if tc.redact != nil && strings.Contains(r.String(), "Secret Plans") {
+			t.Errorf("%s: redacted title logged in %s", tc.name, r.String())
+		}
+	}
+}
+
+func TestSampling(t *testing.T) {
+	const calls = 1000
+	failed := status.Error(codes.Unavailable, "down")
+	for _, tc := range []struct {
+		rate     float64
+		err      error
+		min, max int
+	}{
+		{0, nil, 0, 0},
+		{0, failed, calls, calls},
+		{0.5, nil, calls * 4 / 10, calls * 6 / 10},
+		{0.5, failed, calls, calls},
+		{1, nil, calls, calls},
+	} {
+		var r records
+		intercept := logging.UnaryServerInterceptor(logging.Options{Logger: r.logger(), SampleRate: tc.rate})
+		for i := 0; i < calls; i++ {
+			call(intercept, &bookpb.GetBookRequest{Title: "Java"}, nil, tc.err)
+		}
+		if n := len(r.all(t)); n < tc.min || n > tc.max {
+			t.Errorf("rate %v, error %v: %d records of %d calls, want %d to %d", tc.rate, tc.err, n, calls, tc.min, tc.max)
+		}
+	}
+}
+
+func TestStreamCounts(t *testing.T) {
+	var r records
+	h := testharness.Start(t, testharness.Options{
+		StreamInterceptors: []grpc.StreamServerInterceptor{logging.StreamServerInterceptor(logging.Options{Logger: r.logger(), SampleRate: 1})},
+	})
+	ctx := context.Background()
+
+	all, err := h.Books.GetAllBooks(ctx, &bookpb.GetAllBooksRequest{Title: "Java"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := all.Recv(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	titles, err := h.Books.GetBooksForGivenTitles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Java", "Domain Driven Design"} {
+		if err := titles.Send(&bookpb.GetBooksForGivenTitlesRequest{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := titles.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+
+	each, err := h.Books.GetEachBook(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Java", "Domain Driven Design", "Java"} {
+		if err := each.Send(&bookpb.GetEachBookRequest{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := each.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	each.CloseSend()
+	if _, err := each.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("GetEachBook: got %v, want EOF", err)
+	}
+
+	want := map[string][3]interface{}{
+		"GetAllBooks":            {"server_stream", 1.0, 3.0},
+		"GetBooksForGivenTitles": {"client_stream", 2.0, 1.0},
+		"GetEachBook":            {"bidi_stream", 3.0, 3.0},
+	}
+	recs := r.all(t)
+	if len(recs) != len(want) {
+		t.Fatalf("got %d records, want %d: %s", len(recs), len(want), r.String())
+	}
+	for _, rec := range recs {
+		w, ok := want[rec["grpc.method"].(string)]
+		if !ok {
+			t.Errorf("unexpected record %v", rec)
+			continue
+		}
+		if rec["grpc.type"] != w[0] || rec["messages_received"] != w[1] || rec["messages_sent"] != w[2] {
+			t.Errorf("%s: type %v, received %v, sent %v; want %v, %v, %v", rec["grpc.method"],
+				rec["grpc.type"], rec["messages_received"], rec["messages_sent"], w[0], w[1], w[2])
+		}
+		if rec["request_size"].(float64) <= 0 || rec["response_size"].(float64) <= 0 {
+			t.Errorf("%s: sizes %v and %v, want both positive", rec["grpc.method"], rec["request_size"], rec["response_size"])
+		}
+	}
+}
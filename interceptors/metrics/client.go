@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// ClientMetrics records per-method request counts, status codes, latency,
+// in-flight RPCs and stream message counts on the client side.
+// It implements prometheus.Collector.
+type ClientMetrics struct {
+	*rpcMetrics
+}
+
+// NewClientMetrics returns the client side collectors
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{newRPCMetrics("client")}
+}
+
+// UnaryClientInterceptor records the metrics of unary calls
+func (m *ClientMetrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		c := m.begin("unary", method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		c.end(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor records the metrics of streaming calls. A stream
+// is complete once the last response has been received or it failed.
+func (m *ClientMetrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		c := m.begin(streamType(desc.ClientStreams, desc.ServerStreams), method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			c.end(err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, call: c, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+// clientStream counts the messages going through a client stream
+type clientStream struct {
+	grpc.ClientStream
+	call          *call
+	serverStreams bool
+	once          sync.Once
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.sent()
+	} else if err != io.EOF {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.call.received()
+		if !s.serverStreams {
+			s.finish(nil)
+		}
+	case err == io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() { s.call.end(err) })
+}
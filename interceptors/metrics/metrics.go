@@ -0,0 +1,144 @@
+// Package metrics records Prometheus metrics for gRPC servers and clients and
+// exposes them over HTTP.
+package metrics
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	methodLabels = []string{"grpc_type", "grpc_service", "grpc_method"}
+	codeLabels   = append(append([]string{}, methodLabels...), "grpc_code")
+)
+
+// rpcMetrics are the collectors shared by the server and client side
+type rpcMetrics struct {
+	started  *prometheus.CounterVec
+	handled  *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	received *prometheus.CounterVec
+	sent     *prometheus.CounterVec
+}
+
+func newRPCMetrics(side string) *rpcMetrics {
+	return &rpcMetrics{
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_started_total",
+			Help: "Total number of RPCs started.",
+		}, methodLabels),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_handled_total",
+			Help: "Total number of RPCs completed, by status code.",
+		}, codeLabels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_" + side + "_handling_seconds",
+			Help:    "Latency of RPCs until completion.",
+			Buckets: prometheus.DefBuckets,
+		}, methodLabels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_" + side + "_in_flight",
+			Help: "Number of RPCs currently in progress.",
+		}, methodLabels),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_msg_received_total",
+			Help: "Total number of stream messages received.",
+		}, methodLabels),
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_msg_sent_total",
+			Help: "Total number of stream messages sent.",
+		}, methodLabels),
+	}
+}
+
+func (m *rpcMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.started.Describe(ch)
+	m.handled.Describe(ch)
+	m.latency.Describe(ch)
+	m.inFlight.Describe(ch)
+	m.received.Describe(ch)
+	m.sent.Describe(ch)
+}
+
+func (m *rpcMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.started.Collect(ch)
+	m.handled.Collect(ch)
+	m.latency.Collect(ch)
+	m.inFlight.Collect(ch)
+	m.received.Collect(ch)
+	m.sent.Collect(ch)
+}
+
+// call tracks one RPC from start to completion
+type call struct {
+	m      *rpcMetrics
+	labels []string
+	start  time.Time
+}
+
+func (m *rpcMetrics) begin(kind, fullMethod string) *call {
+	labels := []string{kind, path.Dir(fullMethod)[1:], path.Base(fullMethod)}
+	m.started.WithLabelValues(labels...).Inc()
+	m.inFlight.WithLabelValues(labels...).Inc()
+	return &call{m: m, labels: labels, start: time.Now()}
+}
+
+func (c *call) received() {
+	c.m.received.WithLabelValues(c.labels...).Inc()
+}
+
+func (c *call) sent() {
+	c.m.sent.WithLabelValues(c.labels...).Inc()
+}
+
+func (c *call) end(err error) {
+	c.m.inFlight.WithLabelValues(c.labels...).Dec()
+	c.m.latency.WithLabelValues(c.labels...).Observe(time.Since(c.start).Seconds())
+	c.m.handled.WithLabelValues(append(c.labels, status.Code(err).String())...).Inc()
+}
+
+func streamType(clientStreams, serverStreams bool) string {
+	switch {
+	case clientStreams && serverStreams:
+		return "bidi_stream"
+	case clientStreams:
+		return "client_stream"
+	case serverStreams:
+		return "server_stream"
+	default:
+		return "unary"
+	}
+}
+
+// NewRegistry returns a registry holding the Go runtime and process
+// collectors together with cs
+func NewRegistry(cs ...prometheus.Collector) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	reg.MustRegister(cs...)
+	return reg
+}
+
+// Serve exposes the metrics gathered by g on http://addr/metrics from a
+// background goroutine. The returned server can be used to shut the listener
+// down.
+func Serve(addr string, g prometheus.Gatherer) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(g, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics listener failed: %v", err)
+		}
+	}()
+	return srv
+}
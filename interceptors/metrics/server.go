@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ServerMetrics records per-method request counts, status codes, latency,
+// in-flight RPCs and stream message counts on the server side.
+// It implements prometheus.Collector.
+type ServerMetrics struct {
+	*rpcMetrics
+}
+
+// NewServerMetrics returns the server side collectors
+func NewServerMetrics() *ServerMetrics {
+	return &ServerMetrics{newRPCMetrics("server")}
+}
+
+// UnaryServerInterceptor records the metrics of unary calls
+func (m *ServerMetrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c := m.begin("unary", info.FullMethod)
+		resp, err := handler(ctx, req)
+		c.end(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the metrics of streaming calls
+func (m *ServerMetrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c := m.begin(streamType(info.IsClientStream, info.IsServerStream), info.FullMethod)
+		err := handler(srv, &serverStream{ServerStream: ss, call: c})
+		c.end(err)
+		return err
+	}
+}
+
+// serverStream counts the messages going through a server stream
+type serverStream struct {
+	grpc.ServerStream
+	call *call
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.call.sent()
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.call.received()
+	}
+	return err
+}
@@ -0,0 +1,307 @@
+// Package ratelimit throttles callers with per-method token buckets and
+// enforces daily call quotas.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"gopkg.in/yaml.v3"
+)
+
+// ClientAddrKey is the metadata key through which the REST gateway and the
+// gRPC-Web handlers, calling the server over loopback, pass the address of
+// the client they serve. It is only trusted from loopback peers.
+const ClientAddrKey = "x-client-addr"
+
+// ErrorInfo reasons of the calls rejected by the Limiter
+const (
+	ReasonRateLimited   = "RATE_LIMITED"
+	ReasonQuotaExceeded = "QUOTA_EXCEEDED"
+)
+
+// sweepInterval is how often idle buckets are looked for
+const sweepInterval = time.Minute
+
+// Limit configures the token bucket and daily quota applied to one method
+type Limit struct {
+	// Rate is the number of calls per second refilled into the bucket,
+	// 0 disables rate limiting
+	Rate float64 `yaml:"rate"`
+	// Burst is the size of the bucket, defaults to 1 when Rate is set
+	Burst int `yaml:"burst"`
+	// DailyQuota is the number of calls a client may make per UTC day,
+	// 0 disables the quota
+	DailyQuota int64 `yaml:"daily_quota"`
+}
+
+// Config holds the limits applied to every method, keyed by fully qualified
+// method name. Methods without an entry use Default.
+type Config struct {
+	Default Limit            `yaml:"default"`
+	Methods map[string]Limit `yaml:"methods"`
+}
+
+// LoadConfig reads a YAML rate limit file from disk
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing rate limit config: %w", err)
+	}
+	if err := cfg.Default.validate(); err != nil {
+		return nil, fmt.Errorf("default limit: %w", err)
+	}
+	for method, l := range cfg.Methods {
+		if err := l.validate(); err != nil {
+			return nil, fmt.Errorf("limit for %s: %w", method, err)
+		}
+	}
+	return cfg, nil
+}
+
+func (l Limit) validate() error {
+	if l.Rate < 0 || l.Burst < 0 || l.DailyQuota < 0 {
+		return fmt.Errorf("rate, burst and daily_quota must not be negative")
+	}
+	return nil
+}
+
+func (c *Config) limitFor(method string) Limit {
+	if l, ok := c.Methods[method]; ok {
+		return l
+	}
+	return c.Default
+}
+
+// Usage reports the quota consumed by one client on one method
+type Usage struct {
+	Client   string
+	Method   string
+	Used     int64
+	Limit    int64
+	ResetsAt time.Time
+}
+
+// bucketKey identifies the counters of a client on a method
+type bucketKey struct {
+	client string
+	method string
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	day     time.Time
+	used    int64
+	// last is the time of the last call charged or rejected
+	last time.Time
+}
+
+// Limiter applies the configured limits. It is safe for concurrent use.
+type Limiter struct {
+	cfg *Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+// New returns a Limiter enforcing cfg
+func New(cfg *Config) *Limiter {
+	return &Limiter{cfg: cfg, now: time.Now, buckets: make(map[bucketKey]*bucket)}
+}
+
+// idle reports whether b can be dropped at now without changing the outcome
+// of the next calls: its bucket refilled and its quota counter is from a
+// previous day, or the method has no quota
+func (b *bucket) idle(limit Limit, now time.Time) bool {
+	if limit.DailyQuota > 0 && b.used > 0 && b.day.Equal(startOfDay(now)) {
+		return false
+	}
+	refill := sweepInterval
+	if b.limiter != nil {
+		full := time.Duration(float64(b.limiter.Burst()) / limit.Rate * float64(time.Second))
+		refill = max(refill, full)
+	}
+	return now.Sub(b.last) >= refill
+}
+
+// sweep drops the idle buckets, at most every sweepInterval, so clients
+// seen once do not keep memory forever. l.mu must be held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.idle(l.cfg.limitFor(key.method), now) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Allow charges one call of method to the client in ctx. It returns a
+// codes.ResourceExhausted error carrying an ErrorInfo, with ReasonRateLimited
+// or ReasonQuotaExceeded, and a RetryInfo detail when the client is over its
+// rate or daily quota.
+func (l *Limiter) Allow(ctx context.Context, method string) error {
+	limit := l.cfg.limitFor(method)
+	if limit.Rate == 0 && limit.DailyQuota == 0 {
+		return nil
+	}
+	key := bucketKey{client: ClientKey(ctx), method: method}
+	now := l.now()
+	today := startOfDay(now)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{day: today}
+		if limit.Rate > 0 {
+			burst := limit.Burst
+			if burst == 0 {
+				burst = 1
+			}
+			b.limiter = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+		}
+		l.buckets[key] = b
+	}
+	b.last = now
+	if !b.day.Equal(today) {
+		b.day, b.used = today, 0
+	}
+	if limit.DailyQuota > 0 && b.used >= limit.DailyQuota {
+		return exhausted(ReasonQuotaExceeded, method,
+			fmt.Sprintf("daily quota of %d calls to %s exceeded", limit.DailyQuota, method),
+			today.AddDate(0, 0, 1).Sub(now),
+		)
+	}
+	if b.limiter != nil {
+		r := b.limiter.ReserveN(now, 1)
+		if delay := r.DelayFrom(now); delay > 0 {
+			r.CancelAt(now)
+			return exhausted(ReasonRateLimited, method, fmt.Sprintf("rate limit of %g calls/s to %s exceeded", limit.Rate, method), delay)
+		}
+	}
+	b.used++
+	return nil
+}
+
+// Usage returns the daily quota counters, optionally filtered by client and
+// method, sorted by client then method
+func (l *Limiter) Usage(client, method string) []Usage {
+	now := l.now()
+	today := startOfDay(now)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var usage []Usage
+	for key, b := range l.buckets {
+		if (client != "" && key.client != client) || (method != "" && key.method != method) {
+			continue
+		}
+		used := b.used
+		if !b.day.Equal(today) {
+			used = 0
+		}
+		usage = append(usage, Usage{
+			Client:   key.client,
+			Method:   key.method,
+			Used:     used,
+			Limit:    l.cfg.limitFor(key.method).DailyQuota,
+			ResetsAt: today.AddDate(0, 0, 1),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Client != usage[j].Client {
+			return usage[i].Client < usage[j].Client
+		}
+		return usage[i].Method < usage[j].Method
+	})
+	return usage
+}
+
+// ClientKey identifies the caller: the authenticated subject when available,
+// the peer IP address otherwise. The calls of the gateway and gRPC-Web
+// handlers, coming from loopback, are keyed by the ClientAddrKey they set.
+func ClientKey(ctx context.Context) string {
+	if id, ok := auth.FromContext(ctx); ok {
+		return "subject:" + id.Subject
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		host := Host(p.Addr.String())
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			md, _ := metadata.FromIncomingContext(ctx)
+			// the handlers set the key last, after any value a client sent
+			if values := md.Get(ClientAddrKey); len(values) > 0 {
+				return "peer:" + values[len(values)-1]
+			}
+		}
+		return "peer:" + host
+	}
+	return "unknown"
+}
+
+// Host returns the host of a host:port address, addr itself when it has no
+// port
+func Host(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// UnaryServerInterceptor rejects unary calls over the limits. It must run
+// after the auth interceptor to key buckets by identity.
+func UnaryServerInterceptor(l *Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.Allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects new streams over the limits. Each stream
+// counts as a single call whatever the number of messages.
+func StreamServerInterceptor(l *Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.Allow(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func exhausted(reason, method, msg string, retryAfter time.Duration) error {
+	return apierror.ServerDomain.Error(codes.ResourceExhausted, reason, msg,
+		map[string]string{"method": method},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)},
+	)
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
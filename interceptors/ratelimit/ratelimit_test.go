@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func peerContext(addr string, md metadata.MD) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 40000}})
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func TestClientKey(t *testing.T) {
+	forwarded := metadata.Pairs(ClientAddrKey, "203.0.113.7")
+	for _, tc := range []struct {
+		name, addr string
+		md         metadata.MD
+		want       string
+	}{
+		{"remote peer", "198.51.100.1", nil, "peer:198.51.100.1"},
+		{"gateway call", "127.0.0.1", forwarded, "peer:203.0.113.7"},
+		{"loopback without address", "127.0.0.1", nil, "peer:127.0.0.1"},
+		{"address sent by a remote peer", "198.51.100.1", forwarded, "peer:198.51.100.1"},
+	} {
+		if got := ClientKey(peerContext(tc.addr, tc.md)); got != tc.want {
+			t.Errorf("%s: ClientKey = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIdleBucketsEvicted(t *testing.T) {
+	const quotaMethod, rateMethod = "/svc/Quota", "/svc/Rate"
+	l := New(&Config{
+		Default: Limit{Rate: 1, Burst: 2},
+		Methods: map[string]Limit{quotaMethod: {DailyQuota: 10}},
+	})
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	for i := range 100 {
+		if err := l.Allow(peerContext(fmt.Sprintf("198.51.100.%d", i), nil), rateMethod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Allow(peerContext("198.51.100.200", nil), quotaMethod); err != nil {
+		t.Fatal(err)
+	}
+
+	// refilled buckets go, the quota counted today stays
+	now = now.Add(2 * sweepInterval)
+	if err := l.Allow(peerContext("198.51.100.201", nil), rateMethod); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(l.buckets); n != 2 {
+		t.Errorf("%d buckets after the sweep, want the new one and the quota one", n)
+	}
+	if u := l.Usage("peer:198.51.100.200", quotaMethod); len(u) != 1 || u[0].Used != 1 {
+		t.Errorf("quota usage = %+v, want 1 call", u)
+	}
+
+	// the next day the quota counter is spent too
+	now = now.Add(24 * time.Hour)
+	l.Allow(peerContext("198.51.100.201", nil), rateMethod)
+	if n := len(l.buckets); n != 1 {
+		t.Errorf("%d buckets the next day, want 1", n)
+	}
+}
+
+func TestRejectionReasons(t *testing.T) {
+	const quotaMethod, rateMethod = "/svc/Quota", "/svc/Rate"
+	l := New(&Config{
+		Default: Limit{Rate: 1},
+		Methods: map[string]Limit{quotaMethod: {DailyQuota: 1}},
+	})
+	ctx := peerContext("198.51.100.1", nil)
+	for _, tc := range []struct {
+		method, wantReason string
+	}{
+		{rateMethod, ReasonRateLimited},
+		{quotaMethod, ReasonQuotaExceeded},
+	} {
+		if err := l.Allow(ctx, tc.method); err != nil {
+			t.Fatal(err)
+		}
+		err := l.Allow(ctx, tc.method)
+		if status.Code(err) != codes.ResourceExhausted || apierror.Reason(err) != tc.wantReason {
+			t.Errorf("second call to %s = %v, want ResourceExhausted with reason %s", tc.method, err, tc.wantReason)
+		}
+	}
+}
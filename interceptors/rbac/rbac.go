@@ -0,0 +1,203 @@
+// Package rbac restricts which authenticated callers may invoke which RPC
+// methods, based on a declarative policy file.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"gopkg.in/yaml.v3"
+)
+
+// ReasonPermissionDenied is the ErrorInfo reason of the calls the policy
+// denies to an authenticated caller
+const ReasonPermissionDenied = "PERMISSION_DENIED"
+
+// Rule grants the listed methods to callers holding any of the roles or scopes
+type Rule struct {
+	Name   string   `yaml:"name"`
+	Roles  []string `yaml:"roles"`
+	Scopes []string `yaml:"scopes"`
+	// Methods are fully qualified method names such as
+	// "/book.BookSearchAPI/GetBook". A trailing "*" matches any method with
+	// that prefix, so "/book.BookSearchAPI/*" covers a whole service and
+	// "*" covers everything.
+	Methods []string `yaml:"methods"`
+}
+
+// Policy is the set of rules enforced by the interceptors. Anything not
+// explicitly granted is denied.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+	// Public methods may be called by anyone, including anonymous callers.
+	// They use the same patterns as Rule.Methods.
+	Public []string `yaml:"public"`
+
+	audit *log.Logger
+}
+
+// LoadPolicy reads a YAML policy file from disk
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses a YAML policy document
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parsing RBAC policy: %w", err)
+	}
+	for i, r := range p.Rules {
+		if len(r.Roles) == 0 && len(r.Scopes) == 0 {
+			return nil, fmt.Errorf("RBAC rule %d (%q) grants nothing: it needs roles or scopes", i, r.Name)
+		}
+		for _, m := range r.Methods {
+			if err := validatePattern(m); err != nil {
+				return nil, fmt.Errorf("RBAC rule %d (%q): %w", i, r.Name, err)
+			}
+		}
+	}
+	for _, m := range p.Public {
+		if err := validatePattern(m); err != nil {
+			return nil, fmt.Errorf("RBAC public methods: %w", err)
+		}
+	}
+	p.audit = log.New(os.Stderr, "rbac audit: ", log.LstdFlags)
+	return p, nil
+}
+
+// SetAuditLogger replaces the logger receiving the audit trail of denials
+func (p *Policy) SetAuditLogger(l *log.Logger) {
+	p.audit = l
+}
+
+// IsPublic reports whether method may be called without credentials
+func (p *Policy) IsPublic(method string) bool {
+	for _, pattern := range p.Public {
+		if match(pattern, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether the caller may invoke method
+func (p *Policy) Allowed(id *auth.Identity, method string) bool {
+	for _, r := range p.Rules {
+		if r.grants(id) && r.covers(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize returns nil when the call in ctx may proceed and logs the denial
+// otherwise
+func (p *Policy) authorize(ctx context.Context, method string) error {
+	if p.IsPublic(method) {
+		return nil
+	}
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		p.deny(ctx, method, nil)
+		return apierror.ServerDomain.Error(codes.Unauthenticated, auth.ReasonUnauthenticated, "credentials are required", nil)
+	}
+	if !p.Allowed(id, method) {
+		p.deny(ctx, method, id)
+		return apierror.ServerDomain.Error(codes.PermissionDenied, ReasonPermissionDenied,
+			fmt.Sprintf("%s is not allowed to call %s", id.Subject, method),
+			map[string]string{"method": method})
+	}
+	return nil
+}
+
+func (p *Policy) deny(ctx context.Context, method string, id *auth.Identity) {
+	addr := "unknown"
+	if pr, ok := peer.FromContext(ctx); ok {
+		addr = pr.Addr.String()
+	}
+	if id == nil {
+		p.audit.Printf("denied method=%s peer=%s subject=<anonymous>", method, addr)
+		return
+	}
+	p.audit.Printf("denied method=%s peer=%s subject=%s auth=%s roles=%v scopes=%v",
+		method, addr, id.Subject, id.Method, id.Roles, id.Scopes)
+}
+
+// UnaryServerInterceptor enforces the policy on unary calls. It must run
+// after the auth interceptor.
+func UnaryServerInterceptor(p *Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := p.authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor enforces the policy on streaming calls. It must run
+// after the auth interceptor.
+func StreamServerInterceptor(p *Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := p.authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (r Rule) grants(id *auth.Identity) bool {
+	return intersects(r.Roles, id.Roles) || intersects(r.Scopes, id.Scopes)
+}
+
+func (r Rule) covers(method string) bool {
+	for _, pattern := range r.Methods {
+		if match(pattern, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePattern(pattern string) error {
+	if pattern == "*" {
+		return nil
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("method %q must be fully qualified, e.g. /book.BookSearchAPI/GetBook", pattern)
+	}
+	if i := strings.Index(pattern, "*"); i >= 0 && i != len(pattern)-1 {
+		return fmt.Errorf("method %q may only use * as its last character", pattern)
+	}
+	return nil
+}
+
+func match(pattern, method string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(method, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == method
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package rbac_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/rbac"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const policy = `
+public:
+  - /grpc.health.v1.Health/*
+rules:
+  - name: readers
+    roles: [reader]
+    scopes: [books.read]
+    methods:
+      - /book.BookSearchAPI/Get*
+  - name: sum-only
+    scopes: [sum]
+    methods:
+      - /calculator.CalculatorAPI/Sum
+  - name: administrators
+    roles: [admin]
+    methods:
+      - "*"
+`
+
+func TestParsePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"valid", policy, ""},
+		{"empty", "", ""},
+		{"not YAML", "rules: [", "parsing RBAC policy"},
+		{"rule granting nothing", "rules:\n  - name: nobody\n    methods: [/book.BookSearchAPI/GetBook]\n", "grants nothing"},
+		{"unqualified method", "rules:\n  - roles: [reader]\n    methods: [GetBook]\n", "fully qualified"},
+		{"star inside the method", "rules:\n  - roles: [reader]\n    methods: [/book.*/GetBook]\n", "last character"},
+		{"unqualified public method", "public: [grpc.health.v1.Health/*]\n", "fully qualified"},
+	} {
+		_, err := rbac.ParsePolicy([]byte(tc.doc))
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("%s: got %v, want an error with %q", tc.name, err, tc.wantErr)
+		}
+	}
+	if _, err := rbac.LoadPolicy("../../config/rbac.yaml"); err != nil {
+		t.Errorf("the policy shipped in config: %v", err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	p, err := rbac.ParsePolicy([]byte(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var audit bytes.Buffer
+	p.SetAuditLogger(log.New(&audit, "", 0))
+	intercept := rbac.UnaryServerInterceptor(p)
+	reader := &auth.Identity{Subject: "alice", Method: "jwt", Roles: []string{"reader"}}
+	partner := &auth.Identity{Subject: "partner", Method: "api-key", Scopes: []string{"books.read", "sum"}}
+	admin := &auth.Identity{Subject: "root", Method: "jwt", Roles: []string{"admin"}}
+
+	for _, tc := range []struct {
+		name     string
+		id       *auth.Identity
+		method   string
+		wantCode codes.Code
+	}{
+		{"public method", nil, "/grpc.health.v1.Health/Check", codes.OK},
+		{"anonymous", nil, "/book.BookSearchAPI/GetBook", codes.Unauthenticated},
+		{"role", reader, "/book.BookSearchAPI/GetBook", codes.OK},
+		{"prefix", reader, "/book.BookSearchAPI/GetEachBook", codes.OK},
+		{"outside the prefix", reader, "/book.BookSearchAPI/ListBooks", codes.PermissionDenied},
+		{"prefix of another service", reader, "/book.BookSearchAPIv2/GetBook", codes.PermissionDenied},
+		{"scope", partner, "/book.BookSearchAPI/GetAllBooks", codes.OK},
+		{"exact method", partner, "/calculator.CalculatorAPI/Sum", codes.OK},
+		{"longer than the exact method", partner, "/calculator.CalculatorAPI/SumAll", codes.PermissionDenied},
+		{"role named as a scope", &auth.Identity{Subject: "bob", Scopes: []string{"reader"}}, "/book.BookSearchAPI/GetBook", codes.PermissionDenied},
+		{"no roles", &auth.Identity{Subject: "bob"}, "/book.BookSearchAPI/GetBook", codes.PermissionDenied},
+		{"star", admin, "/calculator.CalculatorAPI/PrimeNumberDecomposition", codes.OK},
+		{"health with credentials", reader, "/grpc.health.v1.Health/Watch", codes.OK},
+	} {
+		audit.Reset()
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}})
+		if tc.id != nil {
+			ctx = auth.NewContext(ctx, tc.id)
+		}
+		called := false
+		_, err := intercept(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, func(context.Context, interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		})
+		if status.Code(err) != tc.wantCode {
+			t.Errorf("%s: %s got %v, want %v", tc.name, tc.method, err, tc.wantCode)
+			continue
+		}
+		if called != (tc.wantCode == codes.OK) {
+			t.Errorf("%s: handler called = %v with %v", tc.name, called, err)
+		}
+		if tc.wantCode == codes.OK {
+			if audit.Len() > 0 {
+				t.Errorf("%s: allowed call audited: %s", tc.name, audit.String())
+			}
+			continue
+		}
+		wantReason := rbac.ReasonPermissionDenied
+		subject := "<anonymous>"
+		if tc.id == nil {
+			wantReason = auth.ReasonUnauthenticated
+		} else {
+			subject = tc.id.Subject
+		}
+		if apierror.Reason(err) != wantReason {
+			t.Errorf("%s: reason %q, want %q", tc.name, apierror.Reason(err), wantReason)
+		}
+		if want := "denied method=" + tc.method + " peer=10.0.0.1:4242 subject=" + subject; !strings.HasPrefix(audit.String(), want) {
+			t.Errorf("%s: audit log %q, want it to start with %q", tc.name, audit.String(), want)
+		}
+	}
+}
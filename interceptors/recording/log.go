@@ -0,0 +1,118 @@
+package recording
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	binlogpb "google.golang.org/grpc/binarylog/grpc_binarylog_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+)
+
+// Call is a call read back from a log
+type Call struct {
+	ID        uint64
+	Method    string
+	Authority string
+	// Metadata is the client metadata recorded
+	Metadata metadata.MD
+	// Timeout is the time the client left to the call, 0 for none
+	Timeout time.Duration
+	Peer    string
+	Start   time.Time
+	// Entries are the entries of the call in order, the client header first
+	Entries []*binlogpb.GrpcLogEntry
+}
+
+// Complete reports whether the call ended before the log was closed
+func (c *Call) Complete() bool {
+	return c.Entries[len(c.Entries)-1].GetType() == binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_TRAILER
+}
+
+// Truncated reports whether a message of the call was truncated
+func (c *Call) Truncated() bool {
+	for _, e := range c.Entries {
+		if e.GetPayloadTruncated() {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the status the call ended with, nil if it is not complete
+func (c *Call) Status() *status.Status {
+	if !c.Complete() {
+		return nil
+	}
+	return TrailerStatus(c.Entries[len(c.Entries)-1].GetTrailer())
+}
+
+// TrailerStatus returns the status recorded in a trailer, details included
+func TrailerStatus(t *binlogpb.Trailer) *status.Status {
+	if len(t.GetStatusDetails()) > 0 {
+		p := &spb.Status{}
+		if err := proto.Unmarshal(t.GetStatusDetails(), p); err == nil {
+			return status.FromProto(p)
+		}
+	}
+	return status.New(codes.Code(t.GetStatusCode()), t.GetStatusMessage())
+}
+
+// ReadFile reads the calls of the log at path
+func ReadFile(path string) ([]*Call, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadLog(f)
+}
+
+// ReadLog reads the calls of a log, in the order they started. Entries of
+// calls whose client header is missing are skipped.
+func ReadLog(r io.Reader) ([]*Call, error) {
+	br := bufio.NewReader(r)
+	var calls []*Call
+	byID := map[uint64]*Call{}
+	for n := 0; ; n++ {
+		e := &binlogpb.GrpcLogEntry{}
+		err := protodelim.UnmarshalFrom(br, e)
+		if err == io.EOF {
+			return calls, nil
+		}
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				// the last entry was cut short, e.g. by a crash
+				return calls, nil
+			}
+			return calls, fmt.Errorf("entry %d: %w", n, err)
+		}
+		if h := e.GetClientHeader(); h != nil {
+			c := &Call{
+				ID:        e.GetCallId(),
+				Method:    h.GetMethodName(),
+				Authority: h.GetAuthority(),
+				Metadata:  metadata.MD{},
+				Timeout:   h.GetTimeout().AsDuration(),
+				Peer:      e.GetPeer().GetAddress(),
+				Start:     e.GetTimestamp().AsTime(),
+			}
+			for _, m := range h.GetMetadata().GetEntry() {
+				c.Metadata.Append(m.GetKey(), string(m.GetValue()))
+			}
+			byID[c.ID] = c
+			calls = append(calls, c)
+		}
+		if c := byID[e.GetCallId()]; c != nil {
+			c.Entries = append(c.Entries, e)
+		}
+	}
+}
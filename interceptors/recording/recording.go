@@ -0,0 +1,383 @@
+// Package recording captures the traffic of a server to a binary log, so that
+// calls seen in production, streams included, can be replayed later against
+// another server with the replay command.
+//
+// The log is a sequence of grpc.binarylog.v1.GrpcLogEntry messages, each
+// prefixed with its varint encoded length. A call is logged as its client
+// header (method, metadata, timeout, peer), every message received and sent,
+// the client half-close, the server header and the trailer holding the
+// status, each entry stamped with the time it happened.
+package recording
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"google.golang.org/grpc"
+	binlogpb "google.golang.org/grpc/binarylog/grpc_binarylog_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultOmitMetadata are the metadata keys left out of the log unless
+// Options.OmitMetadata says otherwise: credentials must not end up in files
+// passed around to reproduce bugs
+var DefaultOmitMetadata = []string{auth.AuthorizationHeader, auth.APIKeyHeader, "cookie"}
+
+// Options configure a Recorder
+type Options struct {
+	// Methods limits the recording to the methods listed, by fully qualified
+	// name, or to whole services with a trailing slash, e.g.
+	// "/book.BookSearchAPI/". Every call is recorded when empty.
+	Methods []string
+	// OmitMetadata lists the metadata keys not recorded, DefaultOmitMetadata
+	// when nil
+	OmitMetadata []string
+	// MaxMessageBytes truncates the messages recorded, 0 for no limit.
+	// Calls with truncated messages cannot be replayed.
+	MaxMessageBytes int
+}
+
+// Recorder writes the calls of a server to a log file
+type Recorder struct {
+	opts Options
+	omit map[string]bool
+
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	err error
+
+	// nextID numbers the calls. It starts from the clock so the calls of
+	// successive runs appended to the same file get distinct IDs.
+	nextID atomic.Uint64
+}
+
+// Open returns a Recorder appending to the log at path
+func Open(path string, opts Options) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	omit := opts.OmitMetadata
+	if omit == nil {
+		omit = DefaultOmitMetadata
+	}
+	r := &Recorder{opts: opts, omit: map[string]bool{}, f: f, w: bufio.NewWriter(f)}
+	for _, k := range omit {
+		r.omit[strings.ToLower(k)] = true
+	}
+	r.nextID.Store(uint64(time.Now().UnixNano()))
+	return r, nil
+}
+
+// Close flushes the log and closes the file. It returns the first error met
+// while writing, if any.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.f.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// UnaryServerInterceptor records unary calls
+func (r *Recorder) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !r.records(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		c := r.begin(ctx, info.FullMethod)
+		c.message(binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_MESSAGE, req)
+		c.log(binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HALF_CLOSE, nil)
+		resp, err := handler(c.wrapContext(ctx), req)
+		if err == nil {
+			c.sendHeader()
+			c.message(binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_MESSAGE, resp)
+		}
+		c.finish(err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records streaming calls, message by message
+func (r *Recorder) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !r.records(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		c := r.begin(ss.Context(), info.FullMethod)
+		err := handler(srv, &recordedStream{ServerStream: ss, c: c, ctx: c.wrapContext(ss.Context())})
+		c.finish(err)
+		return err
+	}
+}
+
+func (r *Recorder) records(fullMethod string) bool {
+	if len(r.opts.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.opts.Methods {
+		if m == fullMethod || (strings.HasSuffix(m, "/") && strings.HasPrefix(fullMethod, m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// write appends an entry to the log. Entries are flushed at the end of each
+// call so a crash loses as little as possible.
+func (r *Recorder) write(e *binlogpb.GrpcLogEntry, flush bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	if _, err := protodelim.MarshalTo(r.w, e); err != nil {
+		r.err = fmt.Errorf("writing the recording: %w", err)
+		return
+	}
+	if flush {
+		if err := r.w.Flush(); err != nil {
+			r.err = fmt.Errorf("writing the recording: %w", err)
+		}
+	}
+}
+
+// call is a call being recorded
+type call struct {
+	r  *Recorder
+	id uint64
+
+	mu         sync.Mutex
+	seq        uint64
+	header     metadata.MD
+	headerSent bool
+	trailer    metadata.MD
+}
+
+func (r *Recorder) begin(ctx context.Context, fullMethod string) *call {
+	c := &call{r: r, id: r.nextID.Add(1)}
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := &binlogpb.ClientHeader{MethodName: fullMethod}
+	if a := md.Get(":authority"); len(a) > 0 {
+		header.Authority = a[0]
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		header.Timeout = durationpb.New(time.Until(deadline))
+	}
+	header.Metadata = r.metadata(md)
+	c.log(binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HEADER, func(e *binlogpb.GrpcLogEntry) {
+		e.Payload = &binlogpb.GrpcLogEntry_ClientHeader{ClientHeader: header}
+		if p, ok := peer.FromContext(ctx); ok {
+			e.Peer = address(p.Addr)
+		}
+	})
+	return c
+}
+
+// metadata converts md, leaving out the pseudo headers and omitted keys
+func (r *Recorder) metadata(md metadata.MD) *binlogpb.Metadata {
+	m := &binlogpb.Metadata{}
+	for k, vs := range md {
+		if strings.HasPrefix(k, ":") || r.omit[k] {
+			continue
+		}
+		for _, v := range vs {
+			m.Entry = append(m.Entry, &binlogpb.MetadataEntry{Key: k, Value: []byte(v)})
+		}
+	}
+	return m
+}
+
+// log writes an entry of the call, completed by set when not nil
+func (c *call) log(typ binlogpb.GrpcLogEntry_EventType, set func(*binlogpb.GrpcLogEntry)) {
+	c.mu.Lock()
+	c.seq++
+	e := &binlogpb.GrpcLogEntry{
+		Timestamp:            timestamppb.Now(),
+		CallId:               c.id,
+		SequenceIdWithinCall: c.seq,
+		Type:                 typ,
+		Logger:               binlogpb.GrpcLogEntry_LOGGER_SERVER,
+	}
+	if set != nil {
+		set(e)
+	}
+	// written under the call lock so the entries of a call stay in order
+	c.r.write(e, typ == binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_TRAILER)
+	c.mu.Unlock()
+}
+
+func (c *call) message(typ binlogpb.GrpcLogEntry_EventType, msg interface{}) {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return
+	}
+	c.log(typ, func(e *binlogpb.GrpcLogEntry) {
+		e.Payload = &binlogpb.GrpcLogEntry_Message{Message: &binlogpb.Message{Length: uint32(len(data)), Data: data}}
+		if max := c.r.opts.MaxMessageBytes; max > 0 && len(data) > max {
+			e.GetMessage().Data = data[:max]
+			e.PayloadTruncated = true
+		}
+	})
+}
+
+func (c *call) setHeader(md metadata.MD) {
+	c.mu.Lock()
+	c.header = metadata.Join(c.header, md)
+	c.mu.Unlock()
+}
+
+func (c *call) setTrailer(md metadata.MD) {
+	c.mu.Lock()
+	c.trailer = metadata.Join(c.trailer, md)
+	c.mu.Unlock()
+}
+
+// sendHeader logs the server header, once, before the first response
+func (c *call) sendHeader() {
+	c.mu.Lock()
+	sent := c.headerSent
+	c.headerSent = true
+	header := c.header
+	c.mu.Unlock()
+	if sent {
+		return
+	}
+	c.log(binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_HEADER, func(e *binlogpb.GrpcLogEntry) {
+		e.Payload = &binlogpb.GrpcLogEntry_ServerHeader{ServerHeader: &binlogpb.ServerHeader{Metadata: c.r.metadata(header)}}
+	})
+}
+
+// finish logs the trailer with the status of the call
+func (c *call) finish(err error) {
+	c.sendHeader()
+	st := status.Convert(err)
+	trailer := &binlogpb.Trailer{StatusCode: uint32(st.Code()), StatusMessage: st.Message()}
+	if len(st.Proto().GetDetails()) > 0 {
+		trailer.StatusDetails, _ = proto.Marshal(st.Proto())
+	}
+	c.mu.Lock()
+	trailer.Metadata = c.r.metadata(c.trailer)
+	c.mu.Unlock()
+	c.log(binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_TRAILER, func(e *binlogpb.GrpcLogEntry) {
+		e.Payload = &binlogpb.GrpcLogEntry_Trailer{Trailer: trailer}
+	})
+}
+
+// wrapContext captures the header and trailer metadata set by the handler
+// through grpc.SetHeader, grpc.SendHeader and grpc.SetTrailer
+func (c *call) wrapContext(ctx context.Context) context.Context {
+	ts := grpc.ServerTransportStreamFromContext(ctx)
+	if ts == nil {
+		return ctx
+	}
+	return grpc.NewContextWithServerTransportStream(ctx, &transportStream{ServerTransportStream: ts, c: c})
+}
+
+type transportStream struct {
+	grpc.ServerTransportStream
+	c *call
+}
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.c.setHeader(md)
+	return s.ServerTransportStream.SetHeader(md)
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error {
+	s.c.setHeader(md)
+	err := s.ServerTransportStream.SendHeader(md)
+	s.c.sendHeader()
+	return err
+}
+
+func (s *transportStream) SetTrailer(md metadata.MD) error {
+	s.c.setTrailer(md)
+	return s.ServerTransportStream.SetTrailer(md)
+}
+
+// recordedStream records the messages of a stream as they go through
+type recordedStream struct {
+	grpc.ServerStream
+	c   *call
+	ctx context.Context
+}
+
+func (s *recordedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *recordedStream) SetHeader(md metadata.MD) error {
+	s.c.setHeader(md)
+	return s.ServerStream.SetHeader(md)
+}
+
+func (s *recordedStream) SendHeader(md metadata.MD) error {
+	s.c.setHeader(md)
+	err := s.ServerStream.SendHeader(md)
+	s.c.sendHeader()
+	return err
+}
+
+func (s *recordedStream) SetTrailer(md metadata.MD) {
+	s.c.setTrailer(md)
+	s.ServerStream.SetTrailer(md)
+}
+
+func (s *recordedStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.c.sendHeader()
+		s.c.message(binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_MESSAGE, m)
+	}
+	return err
+}
+
+func (s *recordedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.c.message(binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_MESSAGE, m)
+	case err == io.EOF:
+		s.c.log(binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HALF_CLOSE, nil)
+	}
+	return err
+}
+
+// address converts the address of a peer
+func address(addr net.Addr) *binlogpb.Address {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		if a.IP.To4() != nil {
+			return &binlogpb.Address{Type: binlogpb.Address_TYPE_IPV4, Address: a.IP.String(), IpPort: uint32(a.Port)}
+		}
+		return &binlogpb.Address{Type: binlogpb.Address_TYPE_IPV6, Address: a.IP.String(), IpPort: uint32(a.Port)}
+	case *net.UnixAddr:
+		return &binlogpb.Address{Type: binlogpb.Address_TYPE_UNIX, Address: a.String()}
+	}
+	return &binlogpb.Address{Type: binlogpb.Address_TYPE_UNKNOWN, Address: addr.String()}
+}
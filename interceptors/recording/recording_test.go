@@ -0,0 +1,81 @@
+package recording_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/recording"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+	binlogpb "google.golang.org/grpc/binarylog/grpc_binarylog_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.binlog")
+	r, err := recording.Open(path, recording.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := testharness.Start(t, testharness.Options{
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{r.UnaryServerInterceptor()},
+		StreamInterceptors: []grpc.StreamServerInterceptor{r.StreamServerInterceptor()},
+	})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "42", "x-api-key", "secret")
+	h.Books.GetBook(ctx, &bookpb.GetBookRequest{Title: "Nope"})
+	stream, err := h.Books.GetEachBook(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&bookpb.GetEachBookRequest{Title: "Java"})
+	stream.Recv()
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("got %v, want the end of the stream", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	calls, err := recording.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+	getBook, each := calls[0], calls[1]
+	if getBook.Method != "/book.BookSearchAPI/GetBook" || getBook.Status().Code() != codes.NotFound {
+		t.Errorf("got %s ending with %v, want GetBook ending with NotFound", getBook.Method, getBook.Status())
+	}
+	if got := getBook.Metadata.Get("x-request-id"); len(got) != 1 || got[0] != "42" {
+		t.Errorf("got x-request-id %q, want 42", got)
+	}
+	if got := getBook.Metadata.Get("x-api-key"); len(got) != 0 {
+		t.Errorf("the API key was recorded: %q", got)
+	}
+	var types []binlogpb.GrpcLogEntry_EventType
+	for _, e := range each.Entries {
+		types = append(types, e.GetType())
+	}
+	want := []binlogpb.GrpcLogEntry_EventType{
+		binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HEADER,
+		binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_MESSAGE,
+		binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_HEADER,
+		binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_MESSAGE,
+		binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HALF_CLOSE,
+		binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_TRAILER,
+	}
+	if len(types) != len(want) {
+		t.Fatalf("got entries %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("got entries %v, want %v", types, want)
+		}
+	}
+}
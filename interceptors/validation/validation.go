@@ -0,0 +1,263 @@
+// Package validation enforces the validation.rules annotations of the proto
+// messages (see validation/validationpb/validation.proto) with gRPC server
+// interceptors. Invalid requests fail with codes.InvalidArgument and a
+// BadRequest detail listing every field violation, before the handler runs.
+package validation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/validation/validationpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ReasonInvalidRequest is the ErrorInfo reason of the validation errors
+const ReasonInvalidRequest = "INVALID_REQUEST"
+
+// patterns caches the compiled regular expressions of the rules
+var patterns sync.Map
+
+// Validate returns the violations of the rules of msg and of the messages it
+// holds. Field paths use the proto field names, e.g. "book.title" or
+// "book[2].price".
+func Validate(msg proto.Message) []*errdetails.BadRequest_FieldViolation {
+	return validateMessage(msg.ProtoReflect(), "")
+}
+
+// Check returns an InvalidArgument error reported by domain when msg breaks
+// its rules, nil otherwise
+func Check(domain apierror.Domain, msg proto.Message) error {
+	if violations := Validate(msg); len(violations) > 0 {
+		return domain.InvalidArgument(ReasonInvalidRequest, violations...)
+	}
+	return nil
+}
+
+// UnaryServerInterceptor validates the request of every unary call
+func UnaryServerInterceptor(domain apierror.Domain) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if msg, ok := req.(proto.Message); ok {
+			if err := Check(domain, msg); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor validates every message received on a stream. An
+// invalid message ends the call: Recv returns the InvalidArgument error to the
+// handler, which returns it to the client.
+func StreamServerInterceptor(domain apierror.Domain) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, domain: domain})
+	}
+}
+
+// serverStream validates the received messages
+type serverStream struct {
+	grpc.ServerStream
+	domain apierror.Domain
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if msg, ok := m.(proto.Message); ok {
+		return Check(s.domain, msg)
+	}
+	return nil
+}
+
+func validateMessage(m protoreflect.Message, prefix string) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + string(fd.Name())
+		rules := fieldRules(fd)
+		switch {
+		case fd.IsMap():
+			// maps hold no annotated field in these protos
+		case fd.IsList():
+			list := m.Get(fd).List()
+			if rules != nil {
+				violations = append(violations, checkList(rules, list.Len(), path)...)
+			}
+			for j := 0; j < list.Len(); j++ {
+				itemPath := fmt.Sprintf("%s[%d]", path, j)
+				violations = append(violations, checkValue(fd, rules, list.Get(j), itemPath)...)
+			}
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			if !m.Has(fd) {
+				if rules.GetRequired() {
+					violations = append(violations, apierror.Violation(path, "is required"))
+				}
+				continue
+			}
+			violations = append(violations, validateMessage(m.Get(fd).Message(), path+".")...)
+		default:
+			if !m.Has(fd) {
+				if rules.GetRequired() {
+					violations = append(violations, apierror.Violation(path, "is required"))
+				}
+				continue
+			}
+			violations = append(violations, checkValue(fd, rules, m.Get(fd), path)...)
+		}
+	}
+	return violations
+}
+
+// fieldRules returns the rules annotating fd, or nil
+func fieldRules(fd protoreflect.FieldDescriptor) *validationpb.FieldRules {
+	opts, ok := fd.Options().(*descriptorpb.FieldOptions)
+	if !ok || opts == nil || !proto.HasExtension(opts, validationpb.E_Rules) {
+		return nil
+	}
+	return proto.GetExtension(opts, validationpb.E_Rules).(*validationpb.FieldRules)
+}
+
+func checkList(rules *validationpb.FieldRules, n int, path string) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	if rules.GetRequired() && n == 0 {
+		violations = append(violations, apierror.Violation(path, "is required"))
+	}
+	if rules.MinItems != nil && n > 0 && n < int(rules.GetMinItems()) {
+		violations = append(violations, apierror.Violation(path, fmt.Sprintf("must hold at least %d items", rules.GetMinItems())))
+	}
+	if rules.MaxItems != nil && n > int(rules.GetMaxItems()) {
+		violations = append(violations, apierror.Violation(path, fmt.Sprintf("must hold at most %d items", rules.GetMaxItems())))
+	}
+	return violations
+}
+
+// checkValue applies the rules to one set value, recursing into messages
+func checkValue(fd protoreflect.FieldDescriptor, rules *validationpb.FieldRules, v protoreflect.Value, path string) []*errdetails.BadRequest_FieldViolation {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return validateMessage(v.Message(), path+".")
+	case protoreflect.StringKind:
+		if rules != nil {
+			return checkString(rules, v.String(), path)
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if rules != nil {
+			return checkNumber(rules, float64(v.Int()), path)
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if rules != nil {
+			return checkNumber(rules, float64(v.Uint()), path)
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		if rules != nil {
+			return checkNumber(rules, v.Float(), path)
+		}
+	}
+	return nil
+}
+
+func checkString(rules *validationpb.FieldRules, s, path string) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	n := utf8.RuneCountInString(s)
+	if rules.MinLen != nil && n < int(rules.GetMinLen()) {
+		violations = append(violations, apierror.Violation(path, fmt.Sprintf("must be at least %d characters long", rules.GetMinLen())))
+	}
+	if rules.MaxLen != nil && n > int(rules.GetMaxLen()) {
+		violations = append(violations, apierror.Violation(path, fmt.Sprintf("must be at most %d characters long", rules.GetMaxLen())))
+	}
+	if p := rules.GetPattern(); p != "" {
+		re, err := compile(p)
+		if err != nil {
+			violations = append(violations, apierror.Violation(path, fmt.Sprintf("has an invalid pattern rule: %v", err)))
+		} else if !re.MatchString(s) {
+			violations = append(violations, apierror.Violation(path, fmt.Sprintf("must match the pattern %s", p)))
+		}
+	}
+	if rules.GetIsbn() && !ValidISBN(s) {
+		violations = append(violations, apierror.Violation(path, "must be a valid ISBN-10 or ISBN-13"))
+	}
+	return violations
+}
+
+func checkNumber(rules *validationpb.FieldRules, x float64, path string) []*errdetails.BadRequest_FieldViolation {
+	var violations []*errdetails.BadRequest_FieldViolation
+	if rules.Gte != nil && x < rules.GetGte() {
+		violations = append(violations, apierror.Violation(path, "must be greater than or equal to "+formatNumber(rules.GetGte())))
+	}
+	if rules.Lte != nil && x > rules.GetLte() {
+		violations = append(violations, apierror.Violation(path, "must be less than or equal to "+formatNumber(rules.GetLte())))
+	}
+	return violations
+}
+
+// formatNumber writes the bounds without exponent, e.g. 1000000000
+func formatNumber(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// ValidISBN reports whether s is an ISBN-10 or ISBN-13 with a valid check
+// digit. Hyphens and spaces are ignored.
+func ValidISBN(s string) bool {
+	s = strings.NewReplacer("-", "", " ", "").Replace(s)
+	switch len(s) {
+	case 10:
+		sum := 0
+		for i := 0; i < 10; i++ {
+			var d int
+			switch c := s[i]; {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case (c == 'X' || c == 'x') && i == 9:
+				d = 10
+			default:
+				return false
+			}
+			sum += (10 - i) * d
+		}
+		return sum%11 == 0
+	case 13:
+		if !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+			return false
+		}
+		sum := 0
+		for i := 0; i < 13; i++ {
+			c := s[i]
+			if c < '0' || c > '9' {
+				return false
+			}
+			if i%2 == 0 {
+				sum += int(c - '0')
+			} else {
+				sum += 3 * int(c-'0')
+			}
+		}
+		return sum%10 == 0
+	}
+	return false
+}
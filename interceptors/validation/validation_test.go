@@ -0,0 +1,209 @@
+package validation_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/validation"
+	"github.com/vpulimamidi/grpc-go-course/validation/validationpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestValidISBN(t *testing.T) {
+	for _, tc := range []struct {
+		isbn string
+		want bool
+	}{
+		{"0306406152", true},
+		{"0-306-40615-2", true},
+		{"0 306 40615 2", true},
+		{"0-8044-2957-X", true},
+		{"0-8044-2957-x", true},
+		{"0-306-40615-3", false},
+		{"08044X9572", false}, // X is only a check digit
+		{"030640615", false},
+		{"03064061522", false},
+		{"978-0-306-40615-7", true},
+		{"9780306406157", true},
+		{"978-0-306-40615-8", false},
+		{"977-0-306-40615-8", false}, // valid check digit, not a book prefix
+		{"978-0-306-40615-X", false},
+		{"978030640615", false},
+		{"97803064061577", false},
+		{"", false},
+		{"----------", false},
+	} {
+		if got := validation.ValidISBN(tc.isbn); got != tc.want {
+			t.Errorf("ValidISBN(%q) = %v, want %v", tc.isbn, got, tc.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// field declares a field of the test messages with the given rules
+func field(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type, repeated bool, typeName string, rules *validationpb.FieldRules) *descriptorpb.FieldDescriptorProto {
+	fd := &descriptorpb.FieldDescriptorProto{
+		Name:     ptr(name),
+		JsonName: ptr(name),
+		Number:   ptr(number),
+		Type:     kind.Enum(),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if repeated {
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+	if typeName != "" {
+		fd.TypeName = ptr(typeName)
+	}
+	if rules != nil {
+		fd.Options = &descriptorpb.FieldOptions{}
+		proto.SetExtension(fd.Options, validationpb.E_Rules, rules)
+	}
+	return fd
+}
+
+// testMessages builds an Item message using every rule, with a pattern rule
+// that does not compile, and an Order holding Items
+func testMessages(t *testing.T) (item, order protoreflect.MessageDescriptor) {
+	t.Helper()
+	const (
+		typeString  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		typeInt64   = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		typeUint32  = descriptorpb.FieldDescriptorProto_TYPE_UINT32
+		typeDouble  = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		typeMessage = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       ptr("validation_test.proto"),
+		Package:    ptr("validationtest"),
+		Syntax:     ptr("proto3"),
+		Dependency: []string{validationpb.File_validationpb_validation_proto.Path()},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: ptr("Item"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, typeString, false, "", &validationpb.FieldRules{Required: true, MinLen: ptr[uint32](2), MaxLen: ptr[uint32](5), Pattern: "^[a-z]+$"}),
+				field("count", 2, typeInt64, false, "", &validationpb.FieldRules{Gte: ptr(1.0), Lte: ptr(10.0)}),
+				field("quantity", 3, typeUint32, false, "", &validationpb.FieldRules{Lte: ptr(5.0)}),
+				field("price", 4, typeDouble, false, "", &validationpb.FieldRules{Gte: ptr(0.0)}),
+				field("code", 5, typeString, false, "", &validationpb.FieldRules{Pattern: "[a-"}),
+				field("isbn", 6, typeString, false, "", &validationpb.FieldRules{Isbn: true}),
+				field("tags", 7, typeString, true, "", &validationpb.FieldRules{MinItems: ptr[uint32](2), MaxItems: ptr[uint32](3), MaxLen: ptr[uint32](3)}),
+			},
+		}, {
+			Name: ptr("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("first", 1, typeMessage, false, ".validationtest.Item", &validationpb.FieldRules{Required: true}),
+				field("items", 2, typeMessage, true, ".validationtest.Item", &validationpb.FieldRules{MaxItems: ptr[uint32](2)}),
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().ByName("Item"), fd.Messages().ByName("Order")
+}
+
+func TestRules(t *testing.T) {
+	itemDesc, orderDesc := testMessages(t)
+	set := func(m *dynamicpb.Message, name string, v interface{}) {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if items, ok := v.([]string); ok {
+			list := m.Mutable(fd).List()
+			for _, s := range items {
+				list.Append(protoreflect.ValueOfString(s))
+			}
+			return
+		}
+		m.Set(fd, protoreflect.ValueOf(v))
+	}
+	item := func(fields ...interface{}) *dynamicpb.Message {
+		m := dynamicpb.NewMessage(itemDesc)
+		set(m, "name", "abc")
+		for i := 0; i < len(fields); i += 2 {
+			set(m, fields[i].(string), fields[i+1])
+		}
+		return m
+	}
+	order := func(first *dynamicpb.Message, items ...*dynamicpb.Message) *dynamicpb.Message {
+		m := dynamicpb.NewMessage(orderDesc)
+		if first != nil {
+			set(m, "first", first)
+		}
+		list := m.Mutable(orderDesc.Fields().ByName("items")).List()
+		for _, it := range items {
+			list.Append(protoreflect.ValueOfMessage(it))
+		}
+		return m
+	}
+
+	for _, tc := range []struct {
+		name string
+		msg  proto.Message
+		// want maps the violating field paths to a part of their description
+		want map[string]string
+	}{
+		{"valid", item(), nil},
+		{"empty optional fields", item("count", int64(0), "code", "", "isbn", ""), nil},
+		{"required", item("name", ""), map[string]string{"name": "is required"}},
+		{"min length", item("name", "a"), map[string]string{"name": "at least 2 characters"}},
+		{"length in characters", item("name", "éèà"), map[string]string{"name": "must match the pattern"}},
+		{"max length", item("name", "abcdef"), map[string]string{"name": "at most 5 characters"}},
+		{"length and pattern", item("name", "ABCDEF"), map[string]string{"name": "at most 5 characters|must match the pattern ^[a-z]+$"}},
+		{"min and max lengths", item("name", "abcde"), nil},
+		{"gte", item("count", int64(-1)), map[string]string{"count": "greater than or equal to 1"}},
+		{"lte", item("count", int64(11)), map[string]string{"count": "less than or equal to 10"}},
+		{"bounds", item("count", int64(10)), nil},
+		{"unsigned", item("quantity", uint32(6)), map[string]string{"quantity": "less than or equal to 5"}},
+		{"double", item("price", -0.5), map[string]string{"price": "greater than or equal to 0"}},
+		{"bad pattern rule", item("code", "a"), map[string]string{"code": "invalid pattern rule"}},
+		{"isbn", item("isbn", "0-8044-2957-x"), nil},
+		{"bad isbn", item("isbn", "0-8044-2957-1"), map[string]string{"isbn": "valid ISBN"}},
+		{"min items", item("tags", []string{"a"}), map[string]string{"tags": "at least 2 items"}},
+		{"max items", item("tags", []string{"a", "b", "c", "d"}), map[string]string{"tags": "at most 3 items"}},
+		{"rules of the items", item("tags", []string{"a", "long"}), map[string]string{"tags[1]": "at most 3 characters"}},
+		{"required message", order(nil), map[string]string{"first": "is required"}},
+		{"nested message", order(item("name", "A")), map[string]string{"first.name": "at least 2 characters|must match the pattern"}},
+		{"repeated messages", order(item(), item(), item("count", int64(20)), item()), map[string]string{"items": "at most 2 items", "items[1].count": "less than or equal to 10"}},
+	} {
+		got := map[string][]string{}
+		for _, v := range validation.Validate(tc.msg) {
+			got[v.GetField()] = append(got[v.GetField()], v.GetDescription())
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got violations %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for path, want := range tc.want {
+			parts := strings.Split(want, "|")
+			if len(got[path]) != len(parts) {
+				t.Errorf("%s: %s has violations %q, want %q", tc.name, path, got[path], parts)
+				continue
+			}
+			for i, part := range parts {
+				if !strings.Contains(got[path][i], part) {
+					t.Errorf("%s: %s violation %q, want it to contain %q", tc.name, path, got[path][i], part)
+				}
+			}
+		}
+	}
+}
+
+// TestBookPaths checks the paths reported for the generated messages
+func TestBookPaths(t *testing.T) {
+	res := &bookpb.GetBooksForGivenTitlesResponse{Book: []*bookpb.Book{
+		{Title: "Java", Author: "Joshua Bloch", Isbn: "978-0134685991"},
+		{Title: "Java", Author: "Kathy Sierra", Price: -1},
+	}}
+	violations := validation.Validate(res)
+	if len(violations) != 1 || violations[0].GetField() != "book[1].price" {
+		t.Errorf("got %v, want book[1].price only", violations)
+	}
+}
@@ -0,0 +1,179 @@
+// Package keepalive holds the connection management settings of the servers
+// and clients: the HTTP/2 pings that keep idle connections open through load
+// balancers and NATs and detect dead peers, the enforcement policy rejecting
+// clients that ping too often, and the maximum idle time and age of server
+// connections.
+//
+// Default is documented in config/keepalive.yaml, which the servers load with
+// -keepalive.
+package keepalive
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings of both sides. The client settings must respect
+// the enforcement policy of the server, or the server closes the connection
+// with a GOAWAY "too_many_pings".
+type Config struct {
+	Server ServerParams `yaml:"server"`
+	Client ClientParams `yaml:"client"`
+}
+
+// ServerParams are the settings of a server
+type ServerParams struct {
+	// Time is the idle time after which the server pings the client, 0 for
+	// the grpc-go default of 2 hours
+	Time time.Duration `yaml:"time"`
+	// Timeout is how long the server waits for the ping ack before closing
+	// the connection, 0 for the grpc-go default of 20s
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxConnectionIdle closes the connections without calls for this long,
+	// 0 for never
+	MaxConnectionIdle time.Duration `yaml:"max_connection_idle"`
+	// MaxConnectionAge closes the connections this old, so clients reconnect
+	// and spread over the replicas, 0 for never. The client gets a GOAWAY
+	// and moves its new calls to a new connection.
+	MaxConnectionAge time.Duration `yaml:"max_connection_age"`
+	// MaxConnectionAgeGrace is how long the calls still running on a
+	// connection closed for its age may take before they are aborted, 0 for
+	// no limit
+	MaxConnectionAgeGrace time.Duration `yaml:"max_connection_age_grace"`
+	// Enforcement is the ping policy imposed on clients
+	Enforcement Enforcement `yaml:"enforcement"`
+}
+
+// Enforcement is the ping policy a server imposes on its clients
+type Enforcement struct {
+	// MinTime is the shortest interval between the pings of a client, 0 for
+	// the grpc-go default of 5 minutes
+	MinTime time.Duration `yaml:"min_time"`
+	// PermitWithoutStream allows pings on connections without calls
+	PermitWithoutStream bool `yaml:"permit_without_stream"`
+}
+
+// ClientParams are the settings of a client
+type ClientParams struct {
+	// Time is the idle time after which the client pings the server, 0 to
+	// never ping. grpc-go raises it to 10s at least.
+	Time time.Duration `yaml:"time"`
+	// Timeout is how long the client waits for the ping ack before closing
+	// the connection, 0 for the grpc-go default of 20s
+	Timeout time.Duration `yaml:"timeout"`
+	// PermitWithoutStream pings connections without calls too
+	PermitWithoutStream bool `yaml:"permit_without_stream"`
+}
+
+// The values grpc-go applies in place of the settings left at 0, or too low
+const (
+	grpcMinTime       = 5 * time.Minute
+	grpcMinClientTime = 10 * time.Second
+)
+
+// Default keeps connections open through load balancers closing them after
+// a minute or more without traffic, notices dead peers within a minute, and
+// moves clients to new connections every half hour. Long GetEachBook streams
+// are aborted 10 minutes after their connection reached its age; clients
+// open a new stream.
+var Default = Config{
+	Server: ServerParams{
+		Time:                  30 * time.Second,
+		Timeout:               10 * time.Second,
+		MaxConnectionIdle:     15 * time.Minute,
+		MaxConnectionAge:      30 * time.Minute,
+		MaxConnectionAgeGrace: 10 * time.Minute,
+		Enforcement: Enforcement{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		},
+	},
+	Client: ClientParams{
+		Time:                30 * time.Second,
+		Timeout:             10 * time.Second,
+		PermitWithoutStream: true,
+	},
+}
+
+// LoadConfig reads a YAML keepalive file from disk. The settings it leaves
+// out keep their Default values.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := Default
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing keepalive config: %w", err)
+	}
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Check returns an error when a setting is negative, or when the clients
+// would ping more often than the servers allow. The intervals compared are
+// the ones grpc-go applies: server.enforcement.min_time 0 allows a ping every
+// 5 minutes, and clients never ping more often than every 10s.
+func (c Config) Check() error {
+	for name, d := range map[string]time.Duration{
+		"server.time":                     c.Server.Time,
+		"server.timeout":                  c.Server.Timeout,
+		"server.max_connection_idle":      c.Server.MaxConnectionIdle,
+		"server.max_connection_age":       c.Server.MaxConnectionAge,
+		"server.max_connection_age_grace": c.Server.MaxConnectionAgeGrace,
+		"server.enforcement.min_time":     c.Server.Enforcement.MinTime,
+		"client.time":                     c.Client.Time,
+		"client.timeout":                  c.Client.Timeout,
+	} {
+		if d < 0 {
+			return fmt.Errorf("%s must not be negative, got %v", name, d)
+		}
+	}
+	minTime := c.Server.Enforcement.MinTime
+	if minTime == 0 {
+		minTime = grpcMinTime
+	}
+	if c.Client.Time > 0 && max(c.Client.Time, grpcMinClientTime) < minTime {
+		return fmt.Errorf("client.time %v is shorter than server.enforcement.min_time %v, the servers would close the connections", c.Client.Time, minTime)
+	}
+	if c.Client.PermitWithoutStream && c.Client.Time > 0 && !c.Server.Enforcement.PermitWithoutStream {
+		return fmt.Errorf("client.permit_without_stream needs server.enforcement.permit_without_stream")
+	}
+	return nil
+}
+
+// ServerOptions returns the server options applying p
+func (p ServerParams) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:                  p.Time,
+			Timeout:               p.Timeout,
+			MaxConnectionIdle:     p.MaxConnectionIdle,
+			MaxConnectionAge:      p.MaxConnectionAge,
+			MaxConnectionAgeGrace: p.MaxConnectionAgeGrace,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             p.Enforcement.MinTime,
+			PermitWithoutStream: p.Enforcement.PermitWithoutStream,
+		}),
+	}
+}
+
+// DialOption returns the dial option applying p, nil when p never pings
+func (p ClientParams) DialOption() grpc.DialOption {
+	if p.Time <= 0 {
+		return nil
+	}
+	return grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:                p.Time,
+		Timeout:             p.Timeout,
+		PermitWithoutStream: p.PermitWithoutStream,
+	})
+}
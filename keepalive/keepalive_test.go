@@ -0,0 +1,104 @@
+package keepalive_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/booksearch"
+	"github.com/vpulimamidi/grpc-go-course/keepalive"
+	"github.com/vpulimamidi/grpc-go-course/testharness"
+	"google.golang.org/grpc"
+)
+
+func TestConfigFileDocumentsDefault(t *testing.T) {
+	cfg, err := keepalive.LoadConfig("../config/keepalive.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *cfg != keepalive.Default {
+		t.Errorf("config/keepalive.yaml = %+v, want keepalive.Default %+v", *cfg, keepalive.Default)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name, yaml string
+		wantErr    string
+	}{
+		{"partial", "server:\n  max_connection_age: 5m\n", ""},
+		{"negative", "server:\n  timeout: -1s\n", "server.timeout must not be negative"},
+		{"pings too often", "server:\n  enforcement:\n    min_time: 1m\n", "shorter than server.enforcement.min_time"},
+		{"default min time", "server:\n  enforcement:\n    min_time: 0s\n", "shorter than server.enforcement.min_time 5m0s"},
+		{"pings raised to 10s", "server:\n  enforcement:\n    min_time: 1s\nclient:\n  time: 1s\n", ""},
+		{"default timeouts", "server:\n  timeout: 0s\nclient:\n  timeout: 0s\n", ""},
+		{"pings without stream", "server:\n  enforcement:\n    permit_without_stream: false\n", "permit_without_stream"},
+		{"no client pings", "server:\n  enforcement:\n    permit_without_stream: false\nclient:\n  time: 0s\n", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keepalive.yaml")
+			if err := os.WriteFile(path, []byte(tc.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := keepalive.LoadConfig(path)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("LoadConfig = %v, want an error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.name == "partial" && (cfg.Server.MaxConnectionAge != 5*time.Minute || cfg.Server.Time != keepalive.Default.Server.Time) {
+				t.Errorf("LoadConfig = %+v, want the default with a 5m max_connection_age", cfg.Server)
+			}
+		})
+	}
+}
+
+// A stream outliving the age of its connection runs to its end within the
+// grace period, and the next calls go to a new connection.
+func TestMaxConnectionAgeGrace(t *testing.T) {
+	params := keepalive.Default.Server
+	params.MaxConnectionAge = 100 * time.Millisecond
+	params.MaxConnectionAgeGrace = 10 * time.Second
+	var books []booksearch.Book
+	for i := range 5 {
+		books = append(books, booksearch.Book{Title: "Java", Author: fmt.Sprintf("Author %d", i)})
+	}
+	h := testharness.Start(t, testharness.Options{
+		Books:          books,
+		StreamInterval: 50 * time.Millisecond,
+		ServerOptions:  params.ServerOptions(),
+		DialOptions:    []grpc.DialOption{keepalive.Default.Client.DialOption()},
+	})
+	ctx := context.Background()
+	stream, err := h.Books.GetAllBooks(ctx, &bookpb.GetAllBooksRequest{Title: "Java"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("GetAllBooks after %d books: %v", n, err)
+		}
+		n++
+	}
+	if n != len(books) {
+		t.Fatalf("GetAllBooks streamed %d books, want %d", n, len(books))
+	}
+	if _, err := h.Books.GetBook(ctx, &bookpb.GetBookRequest{Title: "Java"}); err != nil {
+		t.Fatalf("GetBook after the connection reached its age: %v", err)
+	}
+}
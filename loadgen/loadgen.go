@@ -0,0 +1,233 @@
+// Command loadgen drives the book search and compute servers with a mix of
+// calls, at a fixed rate or a fixed concurrency, and reports the throughput
+// and latency percentiles of each RPC as a table or as JSON:
+//
+//	go run . -mix GetBook=8,GetEachBook=1,Divide=1 -concurrency 50 -duration 30s -ramp-up 5s
+//	go run . -mix GetBook -rate 2000 -concurrency 200 -duration 1m -format json -out report.json
+//
+// Without -rate each of the -concurrency workers makes its next call as soon
+// as the previous one returns (closed loop). With -rate the workers start
+// the calls at that rate overall, -concurrency bounding the calls in flight.
+// During the ramp-up the rate, or the number of workers, grows linearly to
+// the target; its calls are left out of the report.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookclient"
+	"github.com/vpulimamidi/grpc-go-course/cli"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computeclient"
+	"golang.org/x/time/rate"
+)
+
+var (
+	conn cli.ConnFlags
+
+	calcTarget     = flag.String("calc-target", computeclient.DefaultTarget, "Address of the compute server, for Divide and Sum")
+	mix            = flag.String("mix", "GetBook", "Comma separated operations with their weights, e.g. GetBook=8,GetEachBook=1,Divide=1 (operations: "+operationNames()+")")
+	callRate       = flag.Float64("rate", 0, "Calls started per second over all workers, 0 to call as fast as -concurrency allows")
+	concurrency    = flag.Int("concurrency", 10, "Number of workers, i.e. the maximum number of calls in flight")
+	connections    = flag.Int("connections", 1, "Number of connections to each server, shared by the workers")
+	duration       = flag.Duration("duration", 30*time.Second, "Length of the measured run, after the ramp-up")
+	rampUp         = flag.Duration("ramp-up", 0, "Time over which the rate or the workers grow to their target, not measured")
+	titles         = flag.String("titles", "Java,Domain Driven Design", "Comma separated titles requested at random")
+	streamMessages = flag.Int("stream-messages", 5, "Titles sent by each GetBooksForGivenTitles and GetEachBook stream")
+	format         = flag.String("format", "text", "Report format: text or json")
+	out            = flag.String("out", "", "File receiving the report, stdout when empty")
+	progress       = flag.Duration("progress", 5*time.Second, "Interval of the progress lines on stderr, 0 to disable")
+	clientCache    = flag.Int("client-cache", 0, "GetBook responses cached by each connection, as long as the server allows; 0 to measure every call")
+)
+
+func main() {
+	conn.Register(flag.CommandLine, bookclient.DefaultTarget)
+	flag.Parse()
+	w, err := parseMix(*mix)
+	if err != nil {
+		log.Fatalf("Invalid -mix: %v", err)
+	}
+	w.titles = strings.Split(*titles, ",")
+	w.streamMessages = *streamMessages
+	if *concurrency < 1 || *connections < 1 {
+		log.Fatalf("-concurrency and -connections must be at least 1")
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("Invalid -format %q, expected text or json", *format)
+	}
+
+	// measure the servers, not the client: no retries, no hedging and a
+	// deadline of -timeout on every call
+	opts := conn.Options()
+	opts.DisableRetries, opts.DisableHedging = true, true
+	opts.CacheSize = *clientCache
+	pool := make([]clients, *connections)
+	for i := range pool {
+		if pool[i].books, err = bookclient.New(conn.Target, opts); err != nil {
+			log.Fatalf("Failed to connect to the book server: %v", err)
+		}
+		defer pool[i].books.Close()
+		if w.computes() {
+			if pool[i].calc, err = computeclient.New(*calcTarget, opts); err != nil {
+				log.Fatalf("Failed to connect to the compute server: %v", err)
+			}
+			defer pool[i].calc.Close()
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report := run(ctx, pool, w)
+	report.Mix = *mix
+
+	dest := os.Stdout
+	if *out != "" {
+		if dest, err = os.Create(*out); err != nil {
+			log.Fatalf("Failed to create the report: %v", err)
+		}
+		defer dest.Close()
+	}
+	if *format == "json" {
+		err = report.WriteJSON(dest)
+	} else {
+		err = report.WriteText(dest)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write the report: %v", err)
+	}
+}
+
+// run drives the load until the end of the run or until ctx is done, and
+// returns the report of the measured calls
+func run(ctx context.Context, pool []clients, w *workload) *Report {
+	start := time.Now()
+	measureFrom := start.Add(*rampUp)
+	ctx, cancel := context.WithDeadline(ctx, measureFrom.Add(*duration))
+	defer cancel()
+
+	var limiter *rate.Limiter
+	if *callRate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(*callRate), 1)
+		if *rampUp > 0 {
+			go rampRate(ctx, limiter, start)
+		}
+	}
+	var calls, failures atomic.Int64
+	if *progress > 0 {
+		go reportProgress(ctx, start, &calls, &failures)
+	}
+
+	results := make([]map[string]*stats, *concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		results[i] = map[string]*stats{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if limiter == nil && *rampUp > 0 {
+				// the workers join one after the other during the ramp-up
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(*rampUp * time.Duration(i) / time.Duration(*concurrency)):
+				}
+			}
+			rng := rand.New(rand.NewPCG(uint64(start.UnixNano()), uint64(i)))
+			c := pool[i%len(pool)]
+			for ctx.Err() == nil {
+				if limiter != nil && limiter.Wait(ctx) != nil {
+					return
+				}
+				op := w.pick(rng)
+				// calls in flight at the end of the run complete, within
+				// their own deadline
+				callCtx, cancel := conn.Context()
+				begin := time.Now()
+				messages, err := op.call(callCtx, c, w, rng)
+				latency := time.Since(begin)
+				cancel()
+				calls.Add(1)
+				if err != nil {
+					failures.Add(1)
+				}
+				if begin.Before(measureFrom) {
+					continue
+				}
+				s := results[i][op.name]
+				if s == nil {
+					s = &stats{}
+					results[i][op.name] = s
+				}
+				s.add(latency, messages, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seconds := max(time.Since(measureFrom).Seconds(), 0)
+	report := &Report{Start: start, Rate: *callRate, Concurrency: *concurrency, Seconds: seconds}
+	total := &stats{}
+	for _, op := range w.ops {
+		s := &stats{}
+		for _, r := range results {
+			if rs := r[op.name]; rs != nil {
+				s.merge(rs)
+			}
+		}
+		total.merge(s)
+		report.Operations = append(report.Operations, measure(op.name, s, seconds))
+	}
+	report.Total = measure("total", total, seconds)
+	return report
+}
+
+// rampRate raises the rate of the limiter linearly to -rate over the ramp-up
+func rampRate(ctx context.Context, limiter *rate.Limiter, start time.Time) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= *rampUp {
+			limiter.SetLimit(rate.Limit(*callRate))
+			return
+		}
+		// never 0, or the workers would wait for good
+		limiter.SetLimit(rate.Limit(max(*callRate*float64(elapsed)/float64(*rampUp), 1)))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportProgress prints the calls made so far every -progress
+func reportProgress(ctx context.Context, start time.Time, calls, failures *atomic.Int64) {
+	ticker := time.NewTicker(*progress)
+	defer ticker.Stop()
+	var last int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n := calls.Load()
+		phase := ""
+		if time.Since(start) < *rampUp {
+			phase = " (ramping up)"
+		}
+		fmt.Fprintf(os.Stderr, "%5.0fs: %d calls, %.1f calls/s, %d errors%s\n",
+			time.Since(start).Seconds(), n, float64(n-last)/progress.Seconds(), failures.Load(), phase)
+		last = n
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stats accumulate the calls of one operation
+type stats struct {
+	latencies []time.Duration
+	messages  int
+	codes     map[codes.Code]int
+}
+
+func (s *stats) add(latency time.Duration, messages int, err error) {
+	if s.codes == nil {
+		s.codes = map[codes.Code]int{}
+	}
+	s.latencies = append(s.latencies, latency)
+	s.messages += messages
+	s.codes[status.Code(err)]++
+}
+
+func (s *stats) merge(o *stats) {
+	s.latencies = append(s.latencies, o.latencies...)
+	s.messages += o.messages
+	if s.codes == nil {
+		s.codes = map[codes.Code]int{}
+	}
+	for c, n := range o.codes {
+		s.codes[c] += n
+	}
+}
+
+// Report is the result of a run, printed as a table or as JSON
+type Report struct {
+	Start       time.Time `json:"start"`
+	Mix         string    `json:"mix"`
+	Rate        float64   `json:"rate,omitempty"`
+	Concurrency int       `json:"concurrency"`
+	// Seconds is the length of the measured part of the run, after the
+	// ramp-up
+	Seconds    float64       `json:"seconds"`
+	Total      Measurement   `json:"total"`
+	Operations []Measurement `json:"operations"`
+}
+
+// Measurement describes the calls of one operation, or of all of them
+type Measurement struct {
+	Operation string `json:"operation"`
+	Calls     int    `json:"calls"`
+	Errors    int    `json:"errors"`
+	// Codes counts the calls by status code, OK included
+	Codes map[string]int `json:"codes"`
+	// Throughput is the number of calls completed per second
+	Throughput float64 `json:"calls_per_second"`
+	// Messages counts the messages sent and received
+	Messages int     `json:"messages"`
+	Latency  Latency `json:"latency_ms"`
+}
+
+// Latency summarizes the latencies of the calls, in milliseconds
+type Latency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p99_9"`
+	Max  float64 `json:"max"`
+}
+
+func measure(name string, s *stats, seconds float64) Measurement {
+	m := Measurement{Operation: name, Calls: len(s.latencies), Messages: s.messages, Codes: map[string]int{}}
+	for c, n := range s.codes {
+		m.Codes[c.String()] = n
+		if c != codes.OK {
+			m.Errors += n
+		}
+	}
+	if seconds > 0 {
+		m.Throughput = float64(m.Calls) / seconds
+	}
+	if len(s.latencies) == 0 {
+		return m
+	}
+	sorted := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	m.Latency = Latency{
+		Mean: ms(total / time.Duration(len(sorted))),
+		P50:  ms(percentile(sorted, 50)),
+		P90:  ms(percentile(sorted, 90)),
+		P95:  ms(percentile(sorted, 95)),
+		P99:  ms(percentile(sorted, 99)),
+		P999: ms(percentile(sorted, 99.9)),
+		Max:  ms(sorted[len(sorted)-1]),
+	}
+	return m
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.999999) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the report as a table, one line per operation, then the
+// errors by code
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%d calls in %.1fs, %.1f calls/s, %d errors\n\n", r.Total.Calls, r.Seconds, r.Total.Throughput, r.Total.Errors)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\tcalls\terrors\tcalls/s\tmean\tp50\tp90\tp95\tp99\tp99.9\tmax\t")
+	for _, m := range append(r.Operations, r.Total) {
+		l := m.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", m.Operation, m.Calls, m.Errors, m.Throughput,
+			msText(l.Mean), msText(l.P50), msText(l.P90), msText(l.P95), msText(l.P99), msText(l.P999), msText(l.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, m := range r.Operations {
+		var errs []string
+		for c, n := range m.Codes {
+			if c != codes.OK.String() {
+				errs = append(errs, fmt.Sprintf("%s %d", c, n))
+			}
+		}
+		if len(errs) > 0 {
+			sort.Strings(errs)
+			fmt.Fprintf(w, "\n%s errors: %s", m.Operation, strings.Join(errs, ", "))
+		}
+	}
+	if r.Total.Errors > 0 {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func msText(v float64) string {
+	return fmt.Sprintf("%.2fms", v)
+}
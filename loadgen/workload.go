@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookclient"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computeclient"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+)
+
+// clients are the connections a worker calls through
+type clients struct {
+	books *bookclient.Client
+	calc  *computeclient.Client
+}
+
+// operation is one kind of call. It returns the number of messages the call
+// sent and received.
+type operation struct {
+	name     string
+	computes bool
+	call     func(ctx context.Context, c clients, w *workload, rng *rand.Rand) (int, error)
+}
+
+var operations = []operation{
+	{name: "GetBook", call: getBook},
+	{name: "GetAllBooks", call: getAllBooks},
+	{name: "GetBooksForGivenTitles", call: getBooksForGivenTitles},
+	{name: "GetEachBook", call: getEachBook},
+	{name: "Divide", computes: true, call: divide},
+	{name: "Sum", computes: true, call: sum},
+}
+
+// workload is the mix of operations and the data sent
+type workload struct {
+	ops     []operation
+	weights []int
+	total   int
+	// titles are the titles requested, picked at random
+	titles []string
+	// streamMessages is the number of titles sent by client and
+	// bidirectional streams
+	streamMessages int
+}
+
+// parseMix parses weighted operations, e.g. "GetBook=5,GetEachBook=1"
+func parseMix(mix string) (*workload, error) {
+	w := &workload{}
+	for _, part := range strings.Split(mix, ",") {
+		name, weight, found := strings.Cut(strings.TrimSpace(part), "=")
+		n := 1
+		if found {
+			var err error
+			if n, err = strconv.Atoi(weight); err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid weight %q for %s", weight, name)
+			}
+		}
+		i := slices.IndexFunc(operations, func(op operation) bool { return op.name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown operation %q, expected one of %s", name, operationNames())
+		}
+		if n == 0 {
+			continue
+		}
+		w.ops = append(w.ops, operations[i])
+		w.weights = append(w.weights, n)
+		w.total += n
+	}
+	if w.total == 0 {
+		return nil, fmt.Errorf("the mix has no operation")
+	}
+	return w, nil
+}
+
+func operationNames() string {
+	var names []string
+	for _, op := range operations {
+		names = append(names, op.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// computes reports whether the mix calls the compute server
+func (w *workload) computes() bool {
+	for _, op := range w.ops {
+		if op.computes {
+			return true
+		}
+	}
+	return false
+}
+
+// pick returns an operation of the mix at random, following the weights
+func (w *workload) pick(rng *rand.Rand) operation {
+	n := rng.IntN(w.total)
+	for i, weight := range w.weights {
+		if n < weight {
+			return w.ops[i]
+		}
+		n -= weight
+	}
+	return w.ops[len(w.ops)-1]
+}
+
+func (w *workload) title(rng *rand.Rand) string {
+	return w.titles[rng.IntN(len(w.titles))]
+}
+
+func getBook(ctx context.Context, c clients, w *workload, rng *rand.Rand) (int, error) {
+	_, err := c.books.GetBook(ctx, &bookpb.GetBookRequest{Title: w.title(rng)})
+	if err != nil {
+		return 1, err
+	}
+	return 2, nil
+}
+
+func getAllBooks(ctx context.Context, c clients, w *workload, rng *rand.Rand) (int, error) {
+	stream, err := c.books.GetAllBooks(ctx, &bookpb.GetAllBooksRequest{Title: w.title(rng)})
+	if err != nil {
+		return 0, err
+	}
+	messages := 1
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return messages, err
+		}
+		messages++
+	}
+}
+
+func getBooksForGivenTitles(ctx context.Context, c clients, w *workload, rng *rand.Rand) (int, error) {
+	stream, err := c.books.GetBooksForGivenTitles(ctx)
+	if err != nil {
+		return 0, err
+	}
+	messages := 0
+	for i := 0; i < w.streamMessages; i++ {
+		if err := stream.Send(&bookpb.GetBooksForGivenTitlesRequest{Title: w.title(rng)}); err != nil {
+			// the server ended the call, CloseAndRecv returns its status
+			break
+		}
+		messages++
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return messages, err
+	}
+	return messages + 1, nil
+}
+
+func getEachBook(ctx context.Context, c clients, w *workload, rng *rand.Rand) (int, error) {
+	stream, err := c.books.GetEachBook(ctx)
+	if err != nil {
+		return 0, err
+	}
+	titles := make([]string, w.streamMessages)
+	for i := range titles {
+		titles[i] = w.title(rng)
+	}
+	// send while receiving, as the books come back as soon as they are found
+	go func() {
+		for _, title := range titles {
+			if err := stream.Send(&bookpb.GetEachBookRequest{Title: title}); err != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+	messages := len(titles)
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return messages, err
+		}
+		messages++
+	}
+}
+
+func divide(ctx context.Context, c clients, _ *workload, rng *rand.Rand) (int, error) {
+	_, err := c.calc.Divide(ctx, &computepb.DivideRequest{Dividend: rng.Int32N(1000), Divisor: 1 + rng.Int32N(100)})
+	if err != nil {
+		return 1, err
+	}
+	return 2, nil
+}
+
+func sum(ctx context.Context, c clients, _ *workload, rng *rand.Rand) (int, error) {
+	_, err := c.calc.Sum(ctx, &computepb.SumRequest{Number1: rng.Int32N(1000), Number2: rng.Int32N(1000)})
+	if err != nil {
+		return 1, err
+	}
+	return 2, nil
+}
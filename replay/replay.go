@@ -0,0 +1,247 @@
+// Command replay re-sends the calls recorded by the recording interceptor to
+// a server and diffs its responses with the recorded ones, message by
+// message, ending with the status:
+//
+//	go run . -log /tmp/books.binlog -target localhost:8979 -api-key dev-reader-key
+//
+// The calls are replayed one after the other, in the order they started,
+// with the recorded metadata (credentials are not recorded, pass them as
+// flags) and the recorded deadline. Streams interleave the messages sent and
+// received as they were recorded; -timing also reproduces the pauses of the
+// client between them. The command exits with status 1 when a response
+// differs.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/vpulimamidi/grpc-go-course/admin/adminpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookclient"
+	"github.com/vpulimamidi/grpc-go-course/cli"
+	_ "github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/recording"
+	"github.com/vpulimamidi/grpc-go-course/rpcclient"
+	"google.golang.org/grpc"
+	binlogpb "google.golang.org/grpc/binarylog/grpc_binarylog_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+var (
+	conn cli.ConnFlags
+
+	logFile      = flag.String("log", "", "Binary log written by the recording interceptor (the -record flag of the servers)")
+	methodFilter = flag.String("method", "", "Replay only the methods containing this string, e.g. GetAllBooks")
+	timing       = flag.Bool("timing", false, "Reproduce the recorded pauses between the messages of a call")
+	sendMetadata = flag.Bool("metadata", true, "Send the recorded client metadata")
+	verbose      = flag.Bool("v", false, "Also list the calls whose responses match")
+)
+
+func main() {
+	conn.Register(flag.CommandLine, bookclient.DefaultTarget)
+	flag.Parse()
+	if *logFile == "" {
+		fmt.Fprintln(os.Stderr, "Usage: replay -log file [flags]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	calls, err := recording.ReadFile(*logFile)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	// the calls are sent as recorded: retries, hedging and health checks
+	// would change what the server sees
+	opts := conn.Options()
+	opts.DisableRetries, opts.DisableHedging, opts.DisableHealthCheck = true, true, true
+	cc, err := rpcclient.Dial(conn.Target, rpcclient.Service{}, opts)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer cc.Close()
+
+	var same, different, skipped int
+	for _, c := range calls {
+		if !strings.Contains(c.Method, *methodFilter) {
+			continue
+		}
+		name := fmt.Sprintf("call %d %s (%s)", c.ID, c.Method, c.Start.Format(time.RFC3339))
+		if reason := skip(c); reason != "" {
+			skipped++
+			fmt.Printf("%s: skipped, %s\n", name, reason)
+			continue
+		}
+		diffs, err := replay(cc, c)
+		switch {
+		case err != nil:
+			skipped++
+			fmt.Printf("%s: skipped, %v\n", name, err)
+		case len(diffs) > 0:
+			different++
+			fmt.Printf("%s: DIFFERENT\n", name)
+			for _, d := range diffs {
+				fmt.Println(indent(d, "  "))
+			}
+		default:
+			same++
+			if *verbose {
+				fmt.Printf("%s: same\n", name)
+			}
+		}
+	}
+	fmt.Printf("%d calls replayed: %d same, %d different, %d skipped\n", same+different, same, different, skipped)
+	if different > 0 {
+		os.Exit(1)
+	}
+}
+
+// skip returns why a call cannot be replayed, "" when it can
+func skip(c *recording.Call) string {
+	switch {
+	case !c.Complete():
+		return "the recording ended before the call"
+	case c.Truncated():
+		return "messages were truncated"
+	}
+	return ""
+}
+
+// replay sends a call again and returns how the responses differ from the
+// recorded ones
+func replay(cc *grpc.ClientConn, c *recording.Call) ([]string, error) {
+	md, err := method(c.Method)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := conn.Context()
+	defer cancel()
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	if *sendMetadata {
+		ctx = metadata.NewOutgoingContext(ctx, outgoing(c.Metadata))
+	}
+	stream, err := cc.NewStream(ctx, &grpc.StreamDesc{
+		StreamName:    string(md.Name()),
+		ServerStreams: md.IsStreamingServer(),
+		ClientStreams: md.IsStreamingClient(),
+	}, c.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	var end error // the error ending the stream, io.EOF on success
+	responses := 0
+	prev := c.Entries[0].GetTimestamp().AsTime()
+	for _, e := range c.Entries[1:] {
+		if *timing && e.GetType() == binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_MESSAGE {
+			time.Sleep(e.GetTimestamp().AsTime().Sub(prev))
+		}
+		prev = e.GetTimestamp().AsTime()
+		if end != nil {
+			continue
+		}
+		switch e.GetType() {
+		case binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_MESSAGE:
+			req := newMessage(md.Input())
+			if err := proto.Unmarshal(e.GetMessage().GetData(), req); err != nil {
+				return nil, fmt.Errorf("decoding a request: %w", err)
+			}
+			// an error means the server ended the call, its status is
+			// returned by RecvMsg
+			stream.SendMsg(req)
+		case binlogpb.GrpcLogEntry_EVENT_TYPE_CLIENT_HALF_CLOSE:
+			stream.CloseSend()
+		case binlogpb.GrpcLogEntry_EVENT_TYPE_SERVER_MESSAGE:
+			responses++
+			want := newMessage(md.Output())
+			if err := proto.Unmarshal(e.GetMessage().GetData(), want); err != nil {
+				return nil, fmt.Errorf("decoding a response: %w", err)
+			}
+			got := newMessage(md.Output())
+			if end = stream.RecvMsg(got); end != nil {
+				diffs = append(diffs, fmt.Sprintf("response %d: missing\n%s", responses, lineDiff(text(want), "")))
+				continue
+			}
+			if !proto.Equal(want, got) {
+				diffs = append(diffs, fmt.Sprintf("response %d:\n%s", responses, lineDiff(text(want), text(got))))
+			}
+		}
+	}
+	// responses the recording does not have
+	for end == nil {
+		got := newMessage(md.Output())
+		if end = stream.RecvMsg(got); end == nil {
+			responses++
+			diffs = append(diffs, fmt.Sprintf("response %d: unexpected\n%s", responses, lineDiff("", text(got))))
+		}
+	}
+	got := status.New(codes.OK, "")
+	if !errors.Is(end, io.EOF) {
+		got = status.Convert(end)
+	}
+	want := c.Status()
+	if !proto.Equal(want.Proto(), got.Proto()) {
+		diffs = append(diffs, fmt.Sprintf("status:\n%s", lineDiff(text(want.Proto()), text(got.Proto()))))
+	}
+	return diffs, nil
+}
+
+// method returns the descriptor of a fully qualified method
+func method(fullMethod string) (protoreflect.MethodDescriptor, error) {
+	service, name, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if ok {
+		if d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service)); err == nil {
+			if sd, ok := d.(protoreflect.ServiceDescriptor); ok {
+				if md := sd.Methods().ByName(protoreflect.Name(name)); md != nil {
+					return md, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown method %s", fullMethod)
+}
+
+// outgoing returns the recorded metadata without the headers set by the
+// gRPC library itself
+func outgoing(md metadata.MD) metadata.MD {
+	out := metadata.MD{}
+	for k, vs := range md {
+		if k == "content-type" || k == "user-agent" || k == "te" || strings.HasPrefix(k, "grpc-") {
+			continue
+		}
+		out[k] = vs
+	}
+	return out
+}
+
+func newMessage(md protoreflect.MessageDescriptor) proto.Message {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName())
+	if err != nil {
+		// the descriptor was found in the same registry as its type
+		panic(err)
+	}
+	return mt.New().Interface()
+}
+
+// text renders a message on several lines for lineDiff
+func text(m proto.Message) string {
+	return prototext.MarshalOptions{Multiline: true}.Format(m)
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+prefix)
+}
@@ -0,0 +1,326 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/health" // client side health checking
+	"google.golang.org/grpc/serviceconfig"
+	"google.golang.org/grpc/status"
+)
+
+// The balancing policies of Options.Balancer. Both only pick backends whose
+// health service reports the service as SERVING, and both eject the backends
+// failing calls in a row.
+const (
+	// RoundRobin sends the calls to each backend in turn
+	RoundRobin = "round_robin"
+	// LeastRequest sends each call to the least busy of two random backends
+	LeastRequest = "least_request"
+)
+
+// policyNames are the names the balancers are registered under
+var policyNames = map[string]string{
+	RoundRobin:   "ejecting_round_robin",
+	LeastRequest: "ejecting_least_request",
+}
+
+// OutlierPolicy ejects the backends failing calls in a row: no call is sent
+// to them for BaseEjectionTime, twice as long the second time they are
+// ejected, three times the third, and so on up to MaxEjectionTime. A
+// successful call after the ejection resets the count.
+type OutlierPolicy struct {
+	// ConsecutiveFailures ejects a backend after that many calls in a row
+	// failed with Unavailable, Internal, Unknown or DataLoss
+	ConsecutiveFailures int
+	BaseEjectionTime    time.Duration
+	MaxEjectionTime     time.Duration
+	// MaxEjectionPercent bounds the share of the backends ejected at once, so
+	// the last backend is never ejected
+	MaxEjectionPercent int
+}
+
+// DefaultOutlierPolicy ejects a backend after 5 failures in a row, for 30s
+// up to 5 minutes, never ejecting more than half of the backends
+var DefaultOutlierPolicy = OutlierPolicy{
+	ConsecutiveFailures: 5,
+	BaseEjectionTime:    30 * time.Second,
+	MaxEjectionTime:     5 * time.Minute,
+	MaxEjectionPercent:  50,
+}
+
+// failureCodes are the codes counted as backend failures. DeadlineExceeded is
+// not: it usually means the deadline of the caller was too short.
+var failureCodes = []codes.Code{codes.Unavailable, codes.Internal, codes.Unknown, codes.DataLoss}
+
+func (p OutlierPolicy) withDefaults() OutlierPolicy {
+	d := DefaultOutlierPolicy
+	if p.ConsecutiveFailures > 0 {
+		d.ConsecutiveFailures = p.ConsecutiveFailures
+	}
+	if p.BaseEjectionTime > 0 {
+		d.BaseEjectionTime = p.BaseEjectionTime
+	}
+	if p.MaxEjectionTime > 0 {
+		d.MaxEjectionTime = p.MaxEjectionTime
+	}
+	if p.MaxEjectionPercent > 0 {
+		d.MaxEjectionPercent = p.MaxEjectionPercent
+	}
+	return d
+}
+
+func init() {
+	balancer.Register(lbBuilder{name: policyNames[RoundRobin]})
+	balancer.Register(lbBuilder{name: policyNames[LeastRequest], leastRequest: true})
+}
+
+// lbConfig is the loadBalancingConfig of the balancers
+type lbConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	BaseEjectionTime    string `json:"baseEjectionTime"`
+	MaxEjectionTime     string `json:"maxEjectionTime"`
+	MaxEjectionPercent  int    `json:"maxEjectionPercent"`
+
+	policy OutlierPolicy
+}
+
+func (p OutlierPolicy) lbConfig() *lbConfig {
+	return &lbConfig{
+		ConsecutiveFailures: p.ConsecutiveFailures,
+		BaseEjectionTime:    durationJSON(p.BaseEjectionTime),
+		MaxEjectionTime:     durationJSON(p.MaxEjectionTime),
+		MaxEjectionPercent:  p.MaxEjectionPercent,
+	}
+}
+
+// lbBuilder builds a base balancer, connected to every backend, whose pickers
+// skip the ejected backends
+type lbBuilder struct {
+	name         string
+	leastRequest bool
+}
+
+func (b lbBuilder) Name() string { return b.name }
+
+func (b lbBuilder) ParseConfig(data json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	cfg := &lbConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", b.name, err)
+	}
+	cfg.policy.ConsecutiveFailures = cfg.ConsecutiveFailures
+	cfg.policy.MaxEjectionPercent = cfg.MaxEjectionPercent
+	for _, d := range []struct {
+		s   string
+		out *time.Duration
+	}{{cfg.BaseEjectionTime, &cfg.policy.BaseEjectionTime}, {cfg.MaxEjectionTime, &cfg.policy.MaxEjectionTime}} {
+		if d.s == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.name, err)
+		}
+		*d.out = v
+	}
+	cfg.policy = cfg.policy.withDefaults()
+	return cfg, nil
+}
+
+func (b lbBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	t := &tracker{leastRequest: b.leastRequest, policy: DefaultOutlierPolicy, backends: map[string]*backend{}}
+	child := base.NewBalancerBuilder(b.name, t, base.Config{HealthCheck: true}).Build(cc, opts)
+	return &lb{Balancer: child, tracker: t}
+}
+
+// lb passes the outlier policy of the service config to its tracker
+type lb struct {
+	balancer.Balancer
+	tracker *tracker
+}
+
+func (l *lb) UpdateClientConnState(s balancer.ClientConnState) error {
+	if cfg, ok := s.BalancerConfig.(*lbConfig); ok {
+		l.tracker.mu.Lock()
+		l.tracker.policy = cfg.policy
+		l.tracker.mu.Unlock()
+	}
+	addrs := map[string]bool{}
+	for _, a := range s.ResolverState.Addresses {
+		addrs[a.Addr] = true
+	}
+	for _, e := range s.ResolverState.Endpoints {
+		for _, a := range e.Addresses {
+			addrs[a.Addr] = true
+		}
+	}
+	l.tracker.forget(addrs)
+	return l.Balancer.UpdateClientConnState(s)
+}
+
+// backend is the state of one address, kept across pickers
+type backend struct {
+	addr        string
+	outstanding atomic.Int32
+
+	// guarded by tracker.mu
+	ready        bool
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// tracker builds the pickers and records the outcome of their calls
+type tracker struct {
+	leastRequest bool
+
+	mu       sync.Mutex
+	policy   OutlierPolicy
+	backends map[string]*backend
+	ready    int
+}
+
+// Build implements base.PickerBuilder
+func (t *tracker) Build(info base.PickerBuildInfo) balancer.Picker {
+	p := &picker{tracker: t, next: rand.Uint32()}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// backends that are not ready keep their ejection state, so a backend
+	// reconnecting is still ejected and ejected longer the next time, but
+	// they do not count against MaxEjectionPercent
+	for _, b := range t.backends {
+		b.ready = false
+	}
+	for sc, sci := range info.ReadySCs {
+		b, ok := t.backends[sci.Address.Addr]
+		if !ok {
+			b = &backend{addr: sci.Address.Addr}
+			t.backends[b.addr] = b
+		}
+		b.ready = true
+		p.subConns = append(p.subConns, sc)
+		p.backends = append(p.backends, b)
+	}
+	t.ready = len(p.backends)
+	if t.ready == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	return p
+}
+
+// forget drops the state of the backends whose address the resolver no
+// longer returns
+func (t *tracker) forget(addrs map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr := range t.backends {
+		if !addrs[addr] {
+			delete(t.backends, addr)
+		}
+	}
+}
+
+// ejected reports whether b is ejected at now
+func (t *tracker) ejected(b *backend, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return now.Before(b.ejectedUntil)
+}
+
+// done records the outcome of a call to b, ejecting it after too many
+// failures in a row
+func (t *tracker) done(b *backend, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil || !slices.Contains(failureCodes, status.Code(err)) {
+		b.failures = 0
+		if time.Now().After(b.ejectedUntil) {
+			b.ejections = 0
+		}
+		return
+	}
+	b.failures++
+	if b.failures < t.policy.ConsecutiveFailures {
+		return
+	}
+	now := time.Now()
+	if now.Before(b.ejectedUntil) {
+		return
+	}
+	ejected := 0
+	for _, other := range t.backends {
+		if other.ready && now.Before(other.ejectedUntil) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > t.ready*t.policy.MaxEjectionPercent {
+		return
+	}
+	b.ejections++
+	d := t.policy.BaseEjectionTime * time.Duration(b.ejections)
+	if d > t.policy.MaxEjectionTime {
+		d = t.policy.MaxEjectionTime
+	}
+	b.ejectedUntil = now.Add(d)
+	b.failures = 0
+}
+
+// picker picks among the ready backends that are not ejected
+type picker struct {
+	tracker  *tracker
+	subConns []balancer.SubConn
+	backends []*backend
+	next     uint32
+}
+
+func (p *picker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	now := time.Now()
+	candidates := make([]int, 0, len(p.backends))
+	for i, b := range p.backends {
+		if !p.tracker.ejected(b, now) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		// cannot happen with MaxEjectionPercent below 100, but never fail a
+		// call because of ejections
+		for i := range p.backends {
+			candidates = append(candidates, i)
+		}
+	}
+	var i int
+	if p.tracker.leastRequest && len(candidates) > 1 {
+		// power of two choices: the least busy of two distinct candidates
+		x := rand.IntN(len(candidates))
+		y := rand.IntN(len(candidates) - 1)
+		if y >= x {
+			y++
+		}
+		i = candidates[x]
+		if p.backends[candidates[y]].outstanding.Load() < p.backends[i].outstanding.Load() {
+			i = candidates[y]
+		}
+	} else {
+		n := atomic.AddUint32(&p.next, 1)
+		i = candidates[int(n)%len(candidates)]
+	}
+	b := p.backends[i]
+	b.outstanding.Add(1)
+	return balancer.PickResult{
+		SubConn: p.subConns[i],
+		Done: func(info balancer.DoneInfo) {
+			b.outstanding.Add(-1)
+			p.tracker.done(b, info.Err)
+		},
+	}, nil
+}
@@ -0,0 +1,99 @@
+package rpcclient
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+// subConn stands for a ready connection to addr
+type subConn struct {
+	balancer.SubConn
+	addr string
+}
+
+func readyInfo(addrs ...string) base.PickerBuildInfo {
+	info := base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{}}
+	for _, addr := range addrs {
+		info.ReadySCs[&subConn{addr: addr}] = base.SubConnInfo{Address: resolver.Address{Addr: addr}}
+	}
+	return info
+}
+
+func TestEjectionBudgetIgnoresGoneBackends(t *testing.T) {
+	tr := &tracker{backends: map[string]*backend{}, policy: OutlierPolicy{
+		ConsecutiveFailures: 1,
+		BaseEjectionTime:    time.Minute,
+		MaxEjectionTime:     time.Minute,
+		MaxEjectionPercent:  50,
+	}}
+	fail := status.Error(codes.Unavailable, "down")
+	eject := func(addr string) bool {
+		tr.done(tr.backends[addr], fail)
+		return tr.ejected(tr.backends[addr], time.Now())
+	}
+
+	tr.Build(readyInfo("a", "b", "c", "d"))
+	if !eject("a") || !eject("b") {
+		t.Fatal("a and b not ejected, want half of the backends ejected")
+	}
+	if eject("c") {
+		t.Fatal("c ejected, want at most half of the backends ejected")
+	}
+
+	// a and b are no longer ready, so c is the only ejection among c and d
+	tr.Build(readyInfo("c", "d"))
+	if !eject("c") {
+		t.Error("c not ejected, want the backends that are not ready left out of the budget")
+	}
+	if eject("d") {
+		t.Error("d ejected, want at most half of the backends ejected")
+	}
+
+	// the state of a and b is only dropped once the resolver removes them
+	if len(tr.backends) != 4 {
+		t.Errorf("%d backends tracked, want the 4 resolved ones", len(tr.backends))
+	}
+	tr.forget(map[string]bool{"c": true, "d": true})
+	if len(tr.backends) != 2 {
+		t.Errorf("%d backends tracked after the resolver update, want 2", len(tr.backends))
+	}
+}
+
+func TestEjectionSurvivesReconnection(t *testing.T) {
+	tr := &tracker{backends: map[string]*backend{}, policy: OutlierPolicy{
+		ConsecutiveFailures: 1,
+		BaseEjectionTime:    time.Minute,
+		MaxEjectionTime:     time.Hour,
+		MaxEjectionPercent:  50,
+	}}
+	fail := status.Error(codes.Unavailable, "down")
+
+	tr.Build(readyInfo("a", "b"))
+	a := tr.backends["a"]
+	tr.done(a, fail)
+	if !tr.ejected(a, time.Now()) {
+		t.Fatal("a not ejected after a failure")
+	}
+
+	// a drops out of READY and comes back still ejected
+	tr.Build(readyInfo("b"))
+	tr.Build(readyInfo("a", "b"))
+	if tr.backends["a"] != a || !tr.ejected(a, time.Now()) {
+		t.Fatal("a lost its ejection while it was not ready")
+	}
+
+	// once the ejection expires, the next one lasts twice as long
+	tr.mu.Lock()
+	a.ejectedUntil = time.Now().Add(-time.Second)
+	tr.mu.Unlock()
+	tr.done(a, fail)
+	if left := time.Until(a.ejectedUntil); left < 90*time.Second {
+		t.Errorf("second ejection lasts %s, want 2 minutes", left)
+	}
+}
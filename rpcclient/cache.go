@@ -0,0 +1,46 @@
+package rpcclient
+
+import (
+	"context"
+
+	"github.com/vpulimamidi/grpc-go-course/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// cacheInterceptor keeps the responses of the idempotent methods of svc for
+// as long as the cache-control header of the server allows, and answers the
+// calls with the same request from the cache. It runs inside the hedging
+// interceptor, so each attempt may be answered by the cache, and the header
+// it asks for does not keep the call from being hedged. Calls passing call
+// options that write back to the caller always reach the server.
+func cacheInterceptor(svc Service, size int) grpc.UnaryClientInterceptor {
+	cached := make(map[string]bool, len(svc.Idempotent))
+	for _, m := range svc.Idempotent {
+		cached["/"+svc.Name+"/"+m] = true
+	}
+	responses := cache.New[string, proto.Message](size)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		in, okIn := req.(proto.Message)
+		out, okOut := reply.(proto.Message)
+		if !cached[method] || !okIn || !okOut || writesBack(opts) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(in)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		key := method + "\x00" + string(data)
+		if res, _, ok := responses.Get(key); ok {
+			proto.Merge(out, res)
+			return nil
+		}
+		var header metadata.MD
+		if err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...); err != nil {
+			return err
+		}
+		responses.Add(key, proto.Clone(out), cache.ParseMaxAge(header))
+		return nil
+	}
+}
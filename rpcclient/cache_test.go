@@ -0,0 +1,69 @@
+package rpcclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vpulimamidi/grpc-go-course/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCacheInterceptor(t *testing.T) {
+	svc := Service{Name: "test.API", Idempotent: []string{"Get"}}
+	intercept := cacheInterceptor(svc, 10)
+	calls := 0
+	// the server answers the request with its value and the header hint
+	hint := "max-age=60"
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		for _, o := range opts {
+			if h, ok := o.(grpc.HeaderCallOption); ok {
+				*h.HeaderAddr = metadata.Pairs(cache.ControlKey, hint)
+			}
+		}
+		proto.Merge(reply.(proto.Message), req.(proto.Message))
+		return nil
+	}
+	call := func(method, value string, opts ...grpc.CallOption) string {
+		t.Helper()
+		reply := &wrapperspb.StringValue{}
+		if err := intercept(context.Background(), method, wrapperspb.String(value), reply, nil, invoker, opts...); err != nil {
+			t.Fatal(err)
+		}
+		return reply.GetValue()
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		value  string
+		opts   []grpc.CallOption
+		calls  int
+	}{
+		{name: "first call", method: "/test.API/Get", value: "a", calls: 1},
+		{name: "cached", method: "/test.API/Get", value: "a", calls: 1},
+		{name: "other request", method: "/test.API/Get", value: "b", calls: 2},
+		{name: "not idempotent", method: "/test.API/Put", value: "a", calls: 3},
+		{name: "not idempotent again", method: "/test.API/Put", value: "a", calls: 4},
+		{name: "header asked by the caller", method: "/test.API/Get", value: "a", opts: []grpc.CallOption{grpc.Header(&metadata.MD{})}, calls: 5},
+	}
+	for _, tt := range tests {
+		if got := call(tt.method, tt.value, tt.opts...); got != tt.value {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.value)
+		}
+		if calls != tt.calls {
+			t.Errorf("%s: %d calls reached the server, want %d", tt.name, calls, tt.calls)
+		}
+	}
+
+	// responses the server forbids caching are not kept
+	hint = "no-store"
+	call("/test.API/Get", "c")
+	call("/test.API/Get", "c")
+	if calls != 7 {
+		t.Errorf("no-store responses: %d calls reached the server, want 7", calls)
+	}
+}
@@ -0,0 +1,131 @@
+package rpcclient
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// HedgingPolicy sends the calls of the idempotent methods again when the
+// first attempt is slow, and keeps the first response. grpc-go ignores the
+// hedgingPolicy of service configs, so the attempts are made by a client
+// interceptor.
+type HedgingPolicy struct {
+	// MaxAttempts is the number of attempts, including the first one
+	MaxAttempts int
+	// Delay is the wait for a response before the next attempt is sent
+	Delay time.Duration
+	// NonFatalCodes send the next attempt immediately instead of failing the
+	// call
+	NonFatalCodes []codes.Code
+}
+
+// DefaultHedgingPolicy sends up to 3 attempts, 100ms apart, and moves on to
+// the next attempt right away when one is Unavailable
+var DefaultHedgingPolicy = HedgingPolicy{
+	MaxAttempts:   3,
+	Delay:         100 * time.Millisecond,
+	NonFatalCodes: []codes.Code{codes.Unavailable},
+}
+
+func (p HedgingPolicy) withDefaults() HedgingPolicy {
+	d := DefaultHedgingPolicy
+	if p.MaxAttempts > 0 {
+		d.MaxAttempts = p.MaxAttempts
+	}
+	if p.Delay > 0 {
+		d.Delay = p.Delay
+	}
+	if len(p.NonFatalCodes) > 0 {
+		d.NonFatalCodes = p.NonFatalCodes
+	}
+	return d
+}
+
+// attempt is the outcome of one hedged attempt
+type attempt struct {
+	reply proto.Message
+	err   error
+}
+
+// hedgingInterceptor hedges the idempotent methods of svc. Calls passing
+// call options that write back to the caller (grpc.Header, grpc.Trailer,
+// grpc.Peer) are not hedged, as concurrent attempts would race on them.
+func hedgingInterceptor(svc Service, p HedgingPolicy) grpc.UnaryClientInterceptor {
+	hedged := make(map[string]bool, len(svc.Idempotent))
+	for _, m := range svc.Idempotent {
+		hedged["/"+svc.Name+"/"+m] = true
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		out, ok := reply.(proto.Message)
+		if !hedged[method] || !ok || p.MaxAttempts < 2 || writesBack(opts) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		// the attempts still running are canceled once the call returns
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		results := make(chan attempt, p.MaxAttempts)
+		send := func() {
+			r := out.ProtoReflect().New().Interface()
+			go func() {
+				results <- attempt{r, invoker(ctx, method, req, r, cc, opts...)}
+			}()
+		}
+		send()
+		sent, pending := 1, 1
+		timer := time.NewTimer(p.Delay)
+		defer timer.Stop()
+		var lastErr error
+		for {
+			select {
+			case res := <-results:
+				pending--
+				if res.err == nil {
+					proto.Merge(out, res.reply)
+					return nil
+				}
+				lastErr = res.err
+				if !slices.Contains(p.NonFatalCodes, status.Code(res.err)) {
+					return res.err
+				}
+				if sent < p.MaxAttempts {
+					send()
+					sent++
+					pending++
+					timer.Reset(p.Delay)
+				} else if pending == 0 {
+					return lastErr
+				}
+			case <-timer.C:
+				if sent < p.MaxAttempts {
+					send()
+					sent++
+					pending++
+					timer.Reset(p.Delay)
+				}
+			case <-ctx.Done():
+				if lastErr != nil {
+					return lastErr
+				}
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		}
+	}
+}
+
+// writesBack reports whether opts fill in values for the caller
+func writesBack(opts []grpc.CallOption) bool {
+	for _, o := range opts {
+		switch o.(type) {
+		case grpc.HeaderCallOption, grpc.TrailerCallOption, grpc.PeerCallOption,
+			*grpc.HeaderCallOption, *grpc.TrailerCallOption, *grpc.PeerCallOption:
+			return true
+		}
+	}
+	return false
+}
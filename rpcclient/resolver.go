@@ -0,0 +1,171 @@
+package rpcclient
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+	"gopkg.in/yaml.v3"
+)
+
+// Besides the dns:/// targets resolved by gRPC (e.g. dns:///books.internal:8989,
+// re-resolved when a connection drops and at most every 30s), two schemes list
+// the backends of a service:
+//
+//	static:///localhost:8989,localhost:8979
+//	file:///etc/books/endpoints.yaml (or file:config/endpoints.yaml, relative)
+//
+// The file holds the addresses as YAML and is watched for changes:
+//
+//	addresses:
+//	  - localhost:8989
+//	  - localhost:8979
+const (
+	StaticScheme = "static"
+	FileScheme   = "file"
+)
+
+// FilePollInterval is how often the endpoints files are checked for changes
+var FilePollInterval = time.Second
+
+func init() {
+	resolver.Register(staticBuilder{})
+	resolver.Register(fileBuilder{})
+}
+
+// staticBuilder resolves static:///host1:port,host2:port
+type staticBuilder struct{}
+
+func (staticBuilder) Scheme() string { return StaticScheme }
+
+func (staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	var addrs []resolver.Address
+	for _, a := range strings.Split(target.Endpoint(), ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, resolver.Address{Addr: a})
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("static target %q lists no address", target.URL.String())
+	}
+	if err := cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		return nil, err
+	}
+	return nopResolver{}, nil
+}
+
+type nopResolver struct{}
+
+func (nopResolver) ResolveNow(resolver.ResolveNowOptions) {}
+func (nopResolver) Close()                                {}
+
+// endpointsFile is the content of the files read by the file resolver
+type endpointsFile struct {
+	Addresses []string `yaml:"addresses"`
+}
+
+// fileBuilder resolves file:///path/to/endpoints.yaml
+type fileBuilder struct{}
+
+func (fileBuilder) Scheme() string { return FileScheme }
+
+func (fileBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	path := target.URL.Path
+	if path == "" {
+		// file:relative/path
+		path = target.URL.Opaque
+	}
+	r := &fileResolver{
+		path: path,
+		cc:   cc,
+		now:  make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	// fail the dial on a missing or invalid file, later errors keep the last
+	// good addresses
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+// fileResolver publishes the addresses of the file whenever it changes
+type fileResolver struct {
+	path    string
+	cc      resolver.ClientConn
+	modTime time.Time
+	lastErr string
+
+	now  chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// load publishes the addresses of the file if it changed since the last load
+func (r *fileResolver) load() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(r.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	var f endpointsFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parsing endpoints %s: %w", r.path, err)
+	}
+	if len(f.Addresses) == 0 {
+		return fmt.Errorf("endpoints %s list no address", r.path)
+	}
+	addrs := make([]resolver.Address, len(f.Addresses))
+	for i, a := range f.Addresses {
+		addrs[i] = resolver.Address{Addr: a}
+	}
+	r.modTime = info.ModTime()
+	return r.cc.UpdateState(resolver.State{Addresses: addrs})
+}
+
+func (r *fileResolver) watch() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(FilePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		case <-r.now:
+		}
+		err := r.load()
+		// log each new error once, not at every poll
+		if err != nil && err.Error() != r.lastErr {
+			log.Printf("Keeping the last addresses of %s: %v", r.path, err)
+		}
+		r.lastErr = ""
+		if err != nil {
+			r.lastErr = err.Error()
+		}
+	}
+}
+
+func (r *fileResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.now <- struct{}{}:
+	default:
+	}
+}
+
+func (r *fileResolver) Close() {
+	close(r.done)
+	r.wg.Wait()
+}
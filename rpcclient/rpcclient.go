@@ -0,0 +1,365 @@
+// Package rpcclient holds the connection plumbing shared by the bookclient and
+// computeclient packages: TLS, credentials, per-call timeouts, a service
+// config retrying Unavailable calls with exponential backoff, hedging and
+// caching of idempotent reads, compression, message size limits, keepalive
+// pings, and client side load balancing over several backends.
+package rpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/compression"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"github.com/vpulimamidi/grpc-go-course/keepalive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// DefaultTimeout bounds the unary calls made without a deadline
+const DefaultTimeout = 10 * time.Second
+
+// Options configure a client connection. The zero value connects without TLS
+// or credentials, bounds unary calls by DefaultTimeout, retries Unavailable
+// calls with DefaultRetryPolicy, hedges the idempotent methods of the service
+// with DefaultHedgingPolicy and pings idle connections as
+// keepalive.Default.Client.
+type Options struct {
+	// TLS connects with TLS, implied by CAFile and CertFile
+	TLS bool
+	// CAFile holds the CA certificate verifying the server, the system roots
+	// are used when empty
+	CAFile string
+	// CertFile and KeyFile hold the client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name checked against the server certificate
+	ServerName string
+
+	// Token is a bearer JWT sent with every call
+	Token string
+	// APIKey is sent with every call when Token is empty
+	APIKey string
+
+	// Timeout bounds the unary calls made without a deadline: 0 means
+	// DefaultTimeout, a negative value no bound. Streams are only bounded by
+	// the deadline of their context.
+	Timeout time.Duration
+
+	// DisableRetries turns the retry policy off
+	DisableRetries bool
+	// Retry overrides the fields of DefaultRetryPolicy it sets
+	Retry RetryPolicy
+
+	// DisableHedging turns hedging off
+	DisableHedging bool
+	// Hedging overrides the fields of DefaultHedgingPolicy it sets
+	Hedging HedgingPolicy
+
+	// Balancer spreads the calls over the addresses the target resolves to:
+	// RoundRobin or LeastRequest. When empty, the calls go to the first
+	// address that accepts a connection.
+	Balancer string
+	// Outlier overrides the fields of DefaultOutlierPolicy it sets
+	Outlier OutlierPolicy
+	// DisableHealthCheck keeps the balancer from watching the health service
+	// of the backends
+	DisableHealthCheck bool
+
+	// Compressor compresses the requests, compression.Gzip or
+	// compression.Zstd; the server may compress its responses with any
+	// registered compressor. Empty sends the requests uncompressed.
+	Compressor string
+	// MaxRecvMsgSize and MaxSendMsgSize bound the size of the messages
+	// received and sent, in bytes; 0 keeps the defaults of grpc-go, 4 MiB
+	// received and no bound on the messages sent
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+
+	// CacheSize is the number of responses of the idempotent methods kept
+	// by the connection, each for as long as the cache-control header of the
+	// server allows; 0 disables the cache
+	CacheSize int
+
+	// DisableKeepalive turns the keepalive pings off
+	DisableKeepalive bool
+	// Keepalive replaces keepalive.Default.Client when its Time is set. The
+	// servers close the connections of clients pinging more often than their
+	// enforcement policy allows, 10s by default.
+	Keepalive keepalive.ClientParams
+
+	// DialOptions are added after the options built from the fields above,
+	// e.g. tracing.DialOption() or metrics interceptors
+	DialOptions []grpc.DialOption
+}
+
+// Service describes the service a connection is made for
+type Service struct {
+	// Name is the fully qualified service name, e.g. "book.BookSearchAPI"
+	Name string
+	// Idempotent lists the unary methods safe to hedge and to cache, e.g.
+	// "GetBook"
+	Idempotent []string
+}
+
+// RetryPolicy is the gRPC retry policy applied by the channel. Calls are
+// retried transparently until a response header is received, so a server
+// stream is not retried once its first message arrived.
+type RetryPolicy struct {
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+	RetryableCodes    []codes.Code
+}
+
+// DefaultRetryPolicy retries Unavailable calls up to 4 times in total, waiting
+// a random delay up to 100ms, 200ms then 400ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:       4,
+	InitialBackoff:    100 * time.Millisecond,
+	MaxBackoff:        2 * time.Second,
+	BackoffMultiplier: 2,
+	RetryableCodes:    []codes.Code{codes.Unavailable},
+}
+
+// Dial returns a connection to target for svc. Like grpc.NewClient, it does
+// not wait for the connection to be established.
+func Dial(target string, svc Service, o Options) (*grpc.ClientConn, error) {
+	secure := o.TLS || o.CAFile != "" || o.CertFile != ""
+	creds := insecure.NewCredentials()
+	if secure {
+		cfg, err := o.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if o.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.NewBearerCredentials(o.Token, secure)))
+	} else if o.APIKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.NewAPIKeyCredentials(o.APIKey, secure)))
+	}
+	sc, err := serviceConfig(svc, o)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, grpc.WithDefaultServiceConfig(sc))
+	if !o.DisableKeepalive {
+		ka := o.Keepalive
+		if ka.Time == 0 {
+			ka = keepalive.Default.Client
+		}
+		if opt := ka.DialOption(); opt != nil {
+			opts = append(opts, opt)
+		}
+	}
+	var callOpts []grpc.CallOption
+	if o.Compressor != "" {
+		if err := compression.Check(o.Compressor); err != nil {
+			return nil, err
+		}
+		callOpts = append(callOpts, grpc.UseCompressor(o.Compressor))
+	}
+	if o.MaxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(o.MaxRecvMsgSize))
+	}
+	if o.MaxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(o.MaxSendMsgSize))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	timeout := o.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	// the timeout is outermost, so it bounds all the hedged attempts together
+	if timeout > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(timeoutInterceptor(timeout)))
+	}
+	if !o.DisableHedging && len(svc.Idempotent) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(hedgingInterceptor(svc, o.Hedging.withDefaults())))
+	}
+	if o.CacheSize > 0 && len(svc.Idempotent) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(cacheInterceptor(svc, o.CacheSize)))
+	}
+	opts = append(opts, o.DialOptions...)
+	return grpc.NewClient(target, opts...)
+}
+
+func (o Options) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: o.ServerName}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", o.CAFile)
+		}
+	}
+	if o.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	d := DefaultRetryPolicy
+	if p.MaxAttempts > 0 {
+		d.MaxAttempts = p.MaxAttempts
+	}
+	if p.InitialBackoff > 0 {
+		d.InitialBackoff = p.InitialBackoff
+	}
+	if p.MaxBackoff > 0 {
+		d.MaxBackoff = p.MaxBackoff
+	}
+	if p.BackoffMultiplier > 0 {
+		d.BackoffMultiplier = p.BackoffMultiplier
+	}
+	if len(p.RetryableCodes) > 0 {
+		d.RetryableCodes = p.RetryableCodes
+	}
+	return d
+}
+
+// methodName is the name of a service config method config
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method,omitempty"`
+}
+
+type retryPolicyJSON struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+type methodConfig struct {
+	Name        []methodName     `json:"name"`
+	RetryPolicy *retryPolicyJSON `json:"retryPolicy,omitempty"`
+}
+
+type retryThrottling struct {
+	MaxTokens  float64 `json:"maxTokens"`
+	TokenRatio float64 `json:"tokenRatio"`
+}
+
+type healthCheckConfig struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type serviceConfigJSON struct {
+	LoadBalancingConfig []map[string]*lbConfig `json:"loadBalancingConfig,omitempty"`
+	HealthCheckConfig   *healthCheckConfig     `json:"healthCheckConfig,omitempty"`
+	MethodConfig        []methodConfig         `json:"methodConfig"`
+	RetryThrottling     *retryThrottling       `json:"retryThrottling,omitempty"`
+}
+
+// serviceConfig returns the JSON service config of svc. The hedged methods
+// get no retry policy: a hedged call already makes several attempts.
+func serviceConfig(svc Service, o Options) (string, error) {
+	var sc serviceConfigJSON
+	if o.Balancer != "" {
+		name, ok := policyNames[o.Balancer]
+		if !ok {
+			return "", fmt.Errorf("unknown balancer %q, want %s or %s", o.Balancer, RoundRobin, LeastRequest)
+		}
+		sc.LoadBalancingConfig = []map[string]*lbConfig{{name: o.Outlier.withDefaults().lbConfig()}}
+		if !o.DisableHealthCheck {
+			sc.HealthCheckConfig = &healthCheckConfig{ServiceName: svc.Name}
+		}
+	}
+	if !o.DisableRetries {
+		p := o.Retry.withDefaults()
+		if p.MaxAttempts < 2 {
+			return "", fmt.Errorf("retry policy needs at least 2 attempts, got %d", p.MaxAttempts)
+		}
+		rp := &retryPolicyJSON{
+			MaxAttempts:       p.MaxAttempts,
+			InitialBackoff:    durationJSON(p.InitialBackoff),
+			MaxBackoff:        durationJSON(p.MaxBackoff),
+			BackoffMultiplier: p.BackoffMultiplier,
+		}
+		for _, c := range p.RetryableCodes {
+			name, ok := codeNames[c]
+			if !ok {
+				return "", fmt.Errorf("retry policy: unknown status code %d", c)
+			}
+			rp.RetryableStatusCodes = append(rp.RetryableStatusCodes, name)
+		}
+		sc.MethodConfig = append(sc.MethodConfig, methodConfig{
+			Name:        []methodName{{Service: svc.Name}},
+			RetryPolicy: rp,
+		})
+		// stop retrying when most calls fail, so retries cannot overload a
+		// struggling server
+		sc.RetryThrottling = &retryThrottling{MaxTokens: 10, TokenRatio: 0.1}
+	}
+	if !o.DisableHedging && len(svc.Idempotent) > 0 {
+		hedged := methodConfig{}
+		for _, m := range svc.Idempotent {
+			hedged.Name = append(hedged.Name, methodName{Service: svc.Name, Method: m})
+		}
+		sc.MethodConfig = append(sc.MethodConfig, hedged)
+	}
+	data, err := json.Marshal(sc)
+	return string(data), err
+}
+
+// durationJSON formats d the way service configs expect, e.g. "0.1s"
+func durationJSON(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
+
+// codeNames are the service config names of the codes. They are not
+// derived from codes.Code.String(): the service config spells Canceled
+// "CANCELLED".
+var codeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// timeoutInterceptor bounds the unary calls made without a deadline
+func timeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
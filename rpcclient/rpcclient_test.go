@@ -0,0 +1,58 @@
+package rpcclient
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// parse has grpc-go parse the service config sc, which NewClient rejects
+// when it is invalid
+func parse(sc string) error {
+	conn, err := grpc.NewClient("passthrough:///unused",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(sc))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestServiceConfigCodes(t *testing.T) {
+	retry := DefaultRetryPolicy
+	retry.RetryableCodes = nil
+	for c := codes.Canceled; c <= codes.Unauthenticated; c++ {
+		retry.RetryableCodes = append(retry.RetryableCodes, c)
+	}
+	svc := Service{Name: "book.BookSearchAPI", Idempotent: []string{"GetBook"}}
+	for _, o := range []Options{
+		{Retry: retry},
+		{Retry: retry, Balancer: LeastRequest},
+	} {
+		sc, err := serviceConfig(svc, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := parse(sc); err != nil {
+			t.Errorf("grpc-go rejects %s: %v", sc, err)
+		}
+		for _, c := range retry.RetryableCodes {
+			if !strings.Contains(sc, `"`+codeNames[c]+`"`) {
+				t.Errorf("%v missing from %s", c, sc)
+			}
+		}
+	}
+
+	// the spelling of codes.Canceled.String() is not the service config one
+	if err := parse(`{"methodConfig": [{"name": [{"service": "book.BookSearchAPI"}], "retryPolicy": {"maxAttempts": 2, "initialBackoff": "0.1s", "maxBackoff": "1s", "backoffMultiplier": 2, "retryableStatusCodes": ["CANCELED"]}}]}`); err == nil {
+		t.Error("grpc-go accepts CANCELED")
+	}
+
+	retry.RetryableCodes = []codes.Code{codes.Code(42)}
+	if _, err := serviceConfig(svc, Options{Retry: retry}); err == nil {
+		t.Error("got a service config for an unknown code")
+	}
+}
@@ -0,0 +1,28 @@
+package rpcclient
+
+import (
+	"io"
+	"iter"
+)
+
+// Recv turns the Recv method of a stream into an iterator over its messages.
+// The iteration ends at the end of the stream, or after yielding the error
+// that failed it.
+func Recv[T any](recv func() (T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			msg, err := recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(msg, nil) {
+				return
+			}
+		}
+	}
+}
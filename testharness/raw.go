@@ -0,0 +1,145 @@
+package testharness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// rawCodec sends and receives messages as the bytes given, so the server
+// decodes whatever the test sends, malformed messages included
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("raw codec: cannot marshal %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("raw codec: cannot unmarshal into %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// Name is the name of the proto codec, which decodes the messages on the
+// server
+func (rawCodec) Name() string { return "proto" }
+
+// RawCall calls method on conn with the encoded request messages and returns
+// the encoded responses. For a streaming method the responses are received
+// while the requests are sent, so a bidirectional stream never blocks on flow
+// control; the requests of a unary or server-streaming method must be exactly
+// one message.
+func RawCall(ctx context.Context, conn *grpc.ClientConn, method string, desc *grpc.StreamDesc, requests [][]byte) ([][]byte, error) {
+	stream, err := conn.NewStream(ctx, desc, method, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return nil, err
+	}
+	sent := make(chan error, 1)
+	go func() {
+		for _, req := range requests {
+			if err := stream.SendMsg(&req); err != nil {
+				// the status comes with RecvMsg
+				sent <- nil
+				return
+			}
+		}
+		sent <- stream.CloseSend()
+	}()
+	var responses [][]byte
+	for {
+		var res []byte
+		err := stream.RecvMsg(&res)
+		if errors.Is(err, io.EOF) {
+			return responses, <-sent
+		}
+		if err != nil {
+			<-sent
+			return responses, err
+		}
+		responses = append(responses, res)
+	}
+}
+
+// SplitMessages cuts data into length-prefixed messages, e.g. the stream
+// sequences of fuzz inputs. A truncated last message is returned as it is.
+func SplitMessages(data []byte) [][]byte {
+	var msgs [][]byte
+	for len(data) > 0 {
+		msg, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return append(msgs, data)
+		}
+		msgs = append(msgs, msg)
+		data = data[n:]
+	}
+	return msgs
+}
+
+// JoinMessages encodes messages for SplitMessages, e.g. to build a seed
+// corpus
+func JoinMessages(msgs ...proto.Message) []byte {
+	var data []byte
+	for _, m := range msgs {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			panic(err)
+		}
+		data = protowire.AppendBytes(data, b)
+	}
+	return data
+}
+
+// FuzzCall makes a RawCall for a fuzz target and checks its outcome: the
+// call ends within 5 seconds, every response decodes as res, and the status
+// is OK, InvalidArgument or one of allowed, with an ErrorInfo. Requests that
+// do not decode as req may also end the call with Internal, the status of
+// the server failing to decode them.
+func FuzzCall(t *testing.T, conn *grpc.ClientConn, method string, desc *grpc.StreamDesc, requests [][]byte, req, res proto.Message, allowed ...codes.Code) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	responses, err := RawCall(ctx, conn, method, desc, requests)
+	for _, r := range responses {
+		if err := proto.Unmarshal(r, res); err != nil {
+			t.Fatalf("%s: invalid %T: %v", method, res, err)
+		}
+	}
+	code := status.Code(err)
+	switch {
+	case code == codes.OK:
+		if !desc.ServerStreams && len(responses) != 1 {
+			t.Fatalf("%s: got %d responses to a successful call, want 1", method, len(responses))
+		}
+	case code == codes.Internal:
+		for _, r := range requests {
+			if proto.Unmarshal(r, req) != nil {
+				return
+			}
+		}
+		t.Fatalf("%s: got %v for requests that all decode", method, err)
+	case code == codes.InvalidArgument || slices.Contains(allowed, code):
+		if apierror.Reason(err) == "" {
+			t.Fatalf("%s: got %v without an ErrorInfo reason", method, err)
+		}
+	default:
+		t.Fatalf("%s: got %v, want OK, InvalidArgument or one of %v", method, err, allowed)
+	}
+}
@@ -0,0 +1,131 @@
+// Package testharness serves BookSearchAPI and CalculatorAPI in-process over
+// bufconn listeners, with a seeded catalog, so tests and benchmarks can call
+// the real handlers through real gRPC clients without opening ports.
+//
+//	h := testharness.Start(t, testharness.Options{})
+//	res, err := h.Books.GetBook(ctx, &bookpb.GetBookRequest{Title: "Java"})
+//
+// The servers run the request validation interceptors, like the production
+// servers; authentication, authorization and rate limiting are left out.
+package testharness
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vpulimamidi/grpc-go-course/apierror"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookerrors"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/bookpb"
+	"github.com/vpulimamidi/grpc-go-course/book-search-service/booksearch"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/calculator"
+	"github.com/vpulimamidi/grpc-go-course/compute-service/computepb"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/validation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bufSize is the buffer of each in-memory connection
+const bufSize = 1 << 20
+
+// Options configure the servers. The zero value serves the sample books and
+// answers without the artificial delays of the production servers.
+type Options struct {
+	// Books seed the catalog, booksearch.SampleBooks() when nil
+	Books []booksearch.Book
+	// StreamInterval is the pause between the books streamed by GetAllBooks
+	StreamInterval time.Duration
+	// MaxResponseSize bounds the GetBooksForGivenTitles responses, see
+	// booksearch.Server
+	MaxResponseSize int
+	// CacheSize and CacheTTL put a booksearch.Cache in front of the catalog
+	// when CacheSize is positive
+	CacheSize int
+	CacheTTL  time.Duration
+	// SumDelay is how long Sum takes to answer
+	SumDelay time.Duration
+
+	// UnaryInterceptors and StreamInterceptors run before the validation
+	// interceptors on both servers
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+	// ServerOptions are added to both servers
+	ServerOptions []grpc.ServerOption
+	// DialOptions are added to both client connections
+	DialOptions []grpc.DialOption
+}
+
+// Harness holds the clients of the in-process servers
+type Harness struct {
+	Books      bookpb.BookSearchAPIClient
+	Calculator computepb.CalculatorAPIClient
+
+	BookConn    *grpc.ClientConn
+	ComputeConn *grpc.ClientConn
+
+	BookServer       *booksearch.Server
+	CalculatorServer *calculator.Server
+}
+
+// Start serves both services until the end of the test
+func Start(tb testing.TB, o Options) *Harness {
+	tb.Helper()
+	books := o.Books
+	if books == nil {
+		books = booksearch.SampleBooks()
+	}
+	h := &Harness{
+		BookServer: &booksearch.Server{
+			Store:           booksearch.NewStore(books),
+			StreamInterval:  o.StreamInterval,
+			MaxResponseSize: o.MaxResponseSize,
+		},
+		CalculatorServer: &calculator.Server{SumDelay: o.SumDelay},
+	}
+	if o.CacheSize > 0 {
+		h.BookServer.Cache = booksearch.NewCache(h.BookServer.Store, o.CacheSize, o.CacheTTL)
+	}
+	h.BookConn = serve(tb, o, bookerrors.Domain, func(s *grpc.Server) {
+		bookpb.RegisterBookSearchAPIServer(s, h.BookServer)
+	})
+	h.ComputeConn = serve(tb, o, calculator.ErrorDomain, func(s *grpc.Server) {
+		computepb.RegisterCalculatorAPIServer(s, h.CalculatorServer)
+	})
+	h.Books = bookpb.NewBookSearchAPIClient(h.BookConn)
+	h.Calculator = computepb.NewCalculatorAPIClient(h.ComputeConn)
+	return h
+}
+
+// serve starts a server on a bufconn listener and returns a connection to it
+func serve(tb testing.TB, o Options, domain apierror.Domain, register func(*grpc.Server)) *grpc.ClientConn {
+	tb.Helper()
+	lis := bufconn.Listen(bufSize)
+	unary := append(append([]grpc.UnaryServerInterceptor{}, o.UnaryInterceptors...), validation.UnaryServerInterceptor(domain))
+	stream := append(append([]grpc.StreamServerInterceptor{}, o.StreamInterceptors...), validation.StreamServerInterceptor(domain))
+	opts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, o.ServerOptions...)
+	s := grpc.NewServer(opts...)
+	register(s)
+	go s.Serve(lis)
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, o.DialOptions...)
+	conn, err := grpc.NewClient("passthrough:///bufnet", dialOpts...)
+	if err != nil {
+		s.Stop()
+		tb.Fatalf("connecting to the in-process server: %v", err)
+	}
+	tb.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+	return conn
+}
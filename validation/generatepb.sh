@@ -0,0 +1,2 @@
+#!/bin/bash
+protoc --go_out=. --go_opt=paths=source_relative validationpb/validation.proto
\ No newline at end of file
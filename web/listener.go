@@ -0,0 +1,125 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// http2Preface starts every HTTP/2 connection, including native gRPC ones
+var http2Preface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+// sniffTimeout bounds how long a new connection may take to send enough
+// bytes to tell HTTP/2 from HTTP/1.x
+const sniffTimeout = 10 * time.Second
+
+// Split shares lis between the native gRPC server, receiving the HTTP/2
+// connections on grpcLis, and the gRPC-Web/Connect server, receiving the
+// HTTP/1.x connections on httpLis. Closing either listener closes lis.
+//
+// The connections are told apart by their first bytes, so lis must not be a
+// TLS listener.
+func Split(lis net.Listener) (grpcLis, httpLis net.Listener) {
+	s := &splitter{
+		root: lis,
+		grpc: make(chan net.Conn),
+		http: make(chan net.Conn),
+		done: make(chan struct{}),
+	}
+	go s.run()
+	return &childListener{s, s.grpc}, &childListener{s, s.http}
+}
+
+type splitter struct {
+	root       net.Listener
+	grpc, http chan net.Conn
+
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error
+}
+
+func (s *splitter) run() {
+	for {
+		conn, err := s.root.Accept()
+		if err != nil {
+			s.close(err)
+			return
+		}
+		go s.route(conn)
+	}
+}
+
+// route reads the start of the connection until it either matches or
+// diverges from the HTTP/2 preface, then hands it to the matching listener
+func (s *splitter) route(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	buf := make([]byte, len(http2Preface))
+	n := 0
+	for n < len(buf) && bytes.Equal(buf[:n], http2Preface[:n]) {
+		m, err := conn.Read(buf[n:])
+		n += m
+		if err != nil && n == 0 {
+			conn.Close()
+			return
+		}
+		if err != nil {
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+	sniffed := &sniffedConn{Conn: conn, r: io.MultiReader(bytes.NewReader(buf[:n]), conn)}
+	target := s.http
+	if bytes.Equal(buf[:n], http2Preface) {
+		target = s.grpc
+	}
+	select {
+	case target <- sniffed:
+	case <-s.done:
+		conn.Close()
+	}
+}
+
+func (s *splitter) close(err error) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		close(s.done)
+		s.root.Close()
+	})
+}
+
+// childListener receives one kind of connection from the splitter
+type childListener struct {
+	s     *splitter
+	conns chan net.Conn
+}
+
+func (l *childListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.s.done:
+		return nil, l.s.err
+	}
+}
+
+func (l *childListener) Close() error {
+	l.s.close(net.ErrClosed)
+	return nil
+}
+
+func (l *childListener) Addr() net.Addr {
+	return l.s.root.Addr()
+}
+
+// sniffedConn replays the bytes read by the splitter
+type sniffedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *sniffedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,236 @@
+// Package web serves the gRPC services to browsers over gRPC-Web and the
+// Connect protocol (JSON and binary), on the same port as native gRPC.
+//
+// The connections of the port are split by protocol: HTTP/2 connections go to
+// the grpc.Server, HTTP/1.x connections to an HTTP server running connect-go
+// handlers. The handlers forward each call to the grpc.Server, so browser
+// calls go through the same authentication, authorization, rate limiting and
+// logging as native gRPC calls.
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/rs/cors"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/auth"
+	"github.com/vpulimamidi/grpc-go-course/interceptors/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// forwardedHeaders are passed to the gRPC server as metadata
+var forwardedHeaders = []string{auth.AuthorizationHeader, auth.APIKeyHeader, "traceparent", "tracestate", "baggage"}
+
+// Route serves one procedure, e.g. "/book.BookSearchAPI/GetBook"
+type Route struct {
+	Procedure string
+	Handler   http.Handler
+}
+
+// Unary returns the route of a unary RPC forwarded to call, e.g. the
+// GetBook method of a bookpb.BookSearchAPIClient
+func Unary[Req, Res any](procedure string, call func(context.Context, *Req, ...grpc.CallOption) (*Res, error)) Route {
+	return Route{procedure, connect.NewUnaryHandler(procedure,
+		func(ctx context.Context, req *connect.Request[Req]) (*connect.Response[Res], error) {
+			res, err := call(outgoingContext(ctx, req.Header(), req.Peer()), req.Msg)
+			if err != nil {
+				return nil, connectError(err)
+			}
+			return connect.NewResponse(res), nil
+		})}
+}
+
+// ServerStream returns the route of a server streaming RPC forwarded to call
+func ServerStream[Req, Res any, S interface{ Recv() (*Res, error) }](procedure string, call func(context.Context, *Req, ...grpc.CallOption) (S, error)) Route {
+	return Route{procedure, connect.NewServerStreamHandler(procedure,
+		func(ctx context.Context, req *connect.Request[Req], out *connect.ServerStream[Res]) error {
+			stream, err := call(outgoingContext(ctx, req.Header(), req.Peer()), req.Msg)
+			if err != nil {
+				return connectError(err)
+			}
+			for {
+				res, err := stream.Recv()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return connectError(err)
+				}
+				if err := out.Send(res); err != nil {
+					return err
+				}
+			}
+		})}
+}
+
+// ClientStream returns the route of a client streaming RPC forwarded to call.
+// Like any client streaming call, it needs an HTTP/2 capable client.
+func ClientStream[Req, Res any, S interface {
+	Send(*Req) error
+	CloseAndRecv() (*Res, error)
+}](procedure string, call func(context.Context, ...grpc.CallOption) (S, error)) Route {
+	return Route{procedure, connect.NewClientStreamHandler(procedure,
+		func(ctx context.Context, in *connect.ClientStream[Req]) (*connect.Response[Res], error) {
+			stream, err := call(outgoingContext(ctx, in.RequestHeader(), in.Peer()))
+			if err != nil {
+				return nil, connectError(err)
+			}
+			for in.Receive() {
+				if err := stream.Send(in.Msg()); err != nil {
+					break
+				}
+			}
+			if err := in.Err(); err != nil {
+				return nil, err
+			}
+			// a failed Send is reported by CloseAndRecv
+			res, err := stream.CloseAndRecv()
+			if err != nil {
+				return nil, connectError(err)
+			}
+			return connect.NewResponse(res), nil
+		})}
+}
+
+// BidiStream returns the route of a bidirectional streaming RPC forwarded to
+// call. Like any bidirectional call, it needs an HTTP/2 capable client.
+func BidiStream[Req, Res any, S interface {
+	Send(*Req) error
+	Recv() (*Res, error)
+	CloseSend() error
+}](procedure string, call func(context.Context, ...grpc.CallOption) (S, error)) Route {
+	return Route{procedure, connect.NewBidiStreamHandler(procedure,
+		func(ctx context.Context, bidi *connect.BidiStream[Req, Res]) error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			stream, err := call(outgoingContext(ctx, bidi.RequestHeader(), bidi.Peer()))
+			if err != nil {
+				return connectError(err)
+			}
+			recvErr := make(chan error, 1)
+			go func() {
+				for {
+					req, err := bidi.Receive()
+					if errors.Is(err, io.EOF) {
+						recvErr <- stream.CloseSend()
+						return
+					}
+					if err != nil {
+						recvErr <- err
+						cancel()
+						return
+					}
+					if err := stream.Send(req); err != nil {
+						// the call failed, Recv returns the status
+						recvErr <- nil
+						return
+					}
+				}
+			}()
+			for {
+				res, err := stream.Recv()
+				if err == io.EOF {
+					return <-recvErr
+				}
+				if err != nil {
+					select {
+					case rerr := <-recvErr:
+						if rerr != nil {
+							return rerr
+						}
+					default:
+					}
+					return connectError(err)
+				}
+				if err := bidi.Send(res); err != nil {
+					return err
+				}
+			}
+		})}
+}
+
+// NewHandler serves the routes, allowing cross-origin calls from the listed
+// origins ("*" for any). No cross-origin call is allowed when origins is
+// empty.
+func NewHandler(origins []string, routes ...Route) http.Handler {
+	mux := http.NewServeMux()
+	for _, r := range routes {
+		mux.Handle(r.Procedure, r.Handler)
+	}
+	if len(origins) == 0 {
+		return mux
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{
+			"Content-Type", "Accept-Encoding", "Content-Encoding",
+			"Connect-Protocol-Version", "Connect-Timeout-Ms",
+			"Connect-Accept-Encoding", "Connect-Content-Encoding",
+			"Grpc-Timeout", "Grpc-Accept-Encoding", "Grpc-Encoding",
+			"X-Grpc-Web", "X-User-Agent",
+			"Authorization", "X-Api-Key", "Traceparent", "Tracestate", "Baggage",
+		},
+		ExposedHeaders: []string{
+			"Content-Encoding", "Connect-Content-Encoding", "Grpc-Encoding",
+			"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin",
+		},
+		MaxAge: int((2 * time.Hour).Seconds()),
+	}).Handler(mux)
+}
+
+// Serve runs an HTTP/1.1 server for h on lis in the background
+func Serve(lis net.Listener, h http.Handler) *http.Server {
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			log.Printf("gRPC-Web/Connect listener failed: %v", err)
+		}
+	}()
+	return srv
+}
+
+// outgoingContext forwards the credentials and trace headers of the browser
+// call, and the address of the browser, to the gRPC server
+func outgoingContext(ctx context.Context, h http.Header, p connect.Peer) context.Context {
+	md := metadata.MD{}
+	// the gRPC server only sees the loopback connection of the handlers
+	if p.Addr != "" {
+		md[ratelimit.ClientAddrKey] = []string{ratelimit.Host(p.Addr)}
+	}
+	for _, key := range forwardedHeaders {
+		if values := h.Values(key); len(values) > 0 {
+			md[strings.ToLower(key)] = values
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// connectError converts a gRPC status to a Connect error with the same code,
+// message and details
+func connectError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	cerr := connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+	for _, d := range st.Proto().GetDetails() {
+		msg, err := d.UnmarshalNew()
+		if err != nil {
+			continue
+		}
+		if detail, err := connect.NewErrorDetail(msg); err == nil {
+			cerr.AddDetail(detail)
+		}
+	}
+	return cerr
+}